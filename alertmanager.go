@@ -0,0 +1,216 @@
+package waiops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlertmanagerMessage is the payload of the Prometheus Alertmanager webhook, version 4
+type AlertmanagerMessage struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts"`
+}
+
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"` //firing or resolved
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// AlertmanagerReceiver is an http.Handler converting the webhook alerts into events sent to the Sink.
+// The delivery is at least once: when an event of a group fails, Alertmanager retries the whole group
+// and the events already forwarded are sent again. The platform deduplicates them into the same alert.
+type AlertmanagerReceiver struct {
+	Sink EventSink
+
+	// LabelMapping maps a label or annotation onto an EvResource field by its json name.
+	// Unmapped labels go to the resource Extras, unmapped annotations to the event Details.
+	// The labels, then the annotations, are read in sorted order and the first one set on a field wins, e.g. app over application.
+	LabelMapping map[string]string
+
+	SeverityLabel   string
+	SeverityMapping map[string]int //label value, lower cased, to the 1-6 scale. Unknown values are indeterminate
+}
+
+func NewAlertmanagerReceiver(sink EventSink) *AlertmanagerReceiver {
+	return &AlertmanagerReceiver{
+		Sink: sink,
+		LabelMapping: map[string]string{
+			"instance":    "hostname",
+			"job":         "service",
+			"pod":         "name",
+			"container":   "component",
+			"app":         "application",
+			"application": "application",
+			"cluster":     "cluster",
+			"location":    "location",
+		},
+		SeverityLabel: "severity",
+		SeverityMapping: map[string]int{
+			"critical":      6,
+			"major":         5,
+			"error":         5,
+			"high":          5,
+			"minor":         4,
+			"warning":       3,
+			"warn":          3,
+			"info":          2,
+			"information":   2,
+			"low":           2,
+			"none":          2,
+			"indeterminate": 1,
+		},
+	}
+}
+
+func (r *AlertmanagerReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg AlertmanagerMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, int64(16*MaxPayloadBytes))).Decode(&msg); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("payload exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("invalid payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, event := range r.Convert(msg) {
+		if err := r.Sink.SendEvent(req.Context(), event); err != nil {
+			// a 5xx makes Alertmanager retry the whole group later
+			log.Printf("Failed to forward event %s: %v", event.Id, err)
+			http.Error(w, fmt.Sprintf("failed to forward event: %v", err), http.StatusBadGateway)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Convert turns every alert of the message into an event
+func (r *AlertmanagerReceiver) Convert(msg AlertmanagerMessage) []EvEvent {
+	events := []EvEvent{}
+	for _, a := range msg.Alerts {
+		events = append(events, r.convertAlert(msg, a))
+	}
+	return events
+}
+
+func (r *AlertmanagerReceiver) convertAlert(msg AlertmanagerMessage, a AlertmanagerAlert) EvEvent {
+	eventType := "problem"
+	occurrence := a.StartsAt
+	if a.Status == "resolved" {
+		eventType = "resolution"
+		occurrence = a.EndsAt
+	}
+	if occurrence.IsZero() {
+		occurrence = time.Now()
+	}
+
+	resource := EvResource{Extras: map[string]any{}}
+	fields := resource.stringFields()
+	details := map[string]string{}
+
+	set := func(key, value string) bool {
+		target, ok := r.LabelMapping[key]
+		if !ok {
+			return false
+		}
+		if target == "port" {
+			resource.Port, _ = strconv.Atoi(value)
+		} else if field, ok := fields[target]; ok && *field == "" {
+			*field = value
+		}
+		return true
+	}
+	for _, k := range sortedKeys(a.Labels) {
+		if v := a.Labels[k]; !set(k, v) && k != "alertname" && k != r.SeverityLabel {
+			resource.Extras[k] = v
+		}
+	}
+	for _, k := range sortedKeys(a.Annotations) {
+		if v := a.Annotations[k]; !set(k, v) {
+			details[k] = v
+		}
+	}
+
+	// instance is host:port for most exporters
+	if host, port, err := net.SplitHostPort(resource.Hostname); err == nil {
+		resource.Hostname = host
+		if resource.Port == 0 {
+			resource.Port, _ = strconv.Atoi(port)
+		}
+	}
+	if resource.Name == "" {
+		resource.Name = resource.Hostname
+	}
+
+	alertname := a.Labels["alertname"]
+	summary := a.Annotations["summary"]
+	if summary == "" {
+		summary = a.Annotations["description"]
+	}
+	if summary == "" {
+		summary = alertname
+	}
+
+	severity, ok := r.SeverityMapping[strings.ToLower(a.Labels[r.SeverityLabel])]
+	if !ok {
+		severity = 1
+	}
+
+	event := EvEvent{
+		Id:             fmt.Sprintf("%s-%s-%d", a.Fingerprint, a.Status, occurrence.UnixMilli()),
+		OccurrenceTime: EvTime(occurrence),
+		Summary:        summary,
+		Severity:       severity,
+		Sender: EvResource{
+			Name:     "Prometheus Alertmanager",
+			Service:  msg.Receiver,
+			SourceId: msg.ExternalURL,
+		},
+		Resource: resource,
+		Type: EvType{
+			Classification: "Prometheus alert",
+			EventType:      eventType,
+			Condition:      alertname,
+		},
+		Details: details,
+	}
+	if a.GeneratorURL != "" {
+		event.Links = []EvLink{{LinkType: "webpage", Name: "Prometheus", Description: "Alert expression", Url: a.GeneratorURL}}
+	}
+	return event
+}
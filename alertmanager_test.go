@@ -0,0 +1,116 @@
+package waiops
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const alertmanagerPayload = `{
+  "version": "4",
+  "status": "firing",
+  "receiver": "aiops",
+  "externalURL": "http://alertmanager:9093",
+  "alerts": [
+    {
+      "status": "firing",
+      "labels": {"alertname": "HighCPU", "instance": "node1:9100", "job": "node", "severity": "critical", "env": "prod"},
+      "annotations": {"summary": "CPU above 90%", "runbook": "http://wiki/cpu"},
+      "startsAt": "2024-05-01T10:00:00Z",
+      "generatorURL": "http://prometheus:9090/graph",
+      "fingerprint": "abc123"
+    },
+    {
+      "status": "resolved",
+      "labels": {"alertname": "HighCPU", "instance": "node2:9100", "severity": "unknown"},
+      "annotations": {},
+      "startsAt": "2024-05-01T09:00:00Z",
+      "endsAt": "2024-05-01T09:30:00Z",
+      "fingerprint": "def456"
+    }
+  ]
+}`
+
+func TestAlertmanagerReceiver(t *testing.T) {
+	events := []EvEvent{}
+	receiver := NewAlertmanagerReceiver(EventSinkFunc(func(ctx context.Context, e EvEvent) error {
+		events = append(events, e)
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	receiver.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(alertmanagerPayload)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	firing := events[0]
+	if firing.Type.EventType != "problem" || firing.Severity != 6 || firing.Summary != "CPU above 90%" {
+		t.Fatalf("unexpected firing event %+v", firing)
+	}
+	if firing.Resource.Hostname != "node1" || firing.Resource.Port != 9100 || firing.Resource.Service != "node" {
+		t.Fatalf("unexpected resource %+v", firing.Resource)
+	}
+	if firing.Resource.Extras["env"] != "prod" || firing.Details["runbook"] != "http://wiki/cpu" {
+		t.Fatalf("expected unmapped labels in extras and annotations in details, got %v and %v", firing.Resource.Extras, firing.Details)
+	}
+	if err := firing.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := events[1]
+	if resolved.Type.EventType != "resolution" || resolved.Severity != 1 || resolved.Type.Condition != firing.Type.Condition {
+		t.Fatalf("unexpected resolved event %+v", resolved)
+	}
+}
+
+func TestAlertmanagerLabelPriority(t *testing.T) {
+	receiver := NewAlertmanagerReceiver(nil)
+	alert := AlertmanagerAlert{
+		Status: "firing",
+		Labels: map[string]string{"alertname": "Down", "app": "shop", "application": "shop-legacy", "instance": "node1:9100"},
+	}
+	//maps are read in a random order, repeat so a dependency on it shows
+	for i := 0; i < 20; i++ {
+		e := receiver.Convert(AlertmanagerMessage{Alerts: []AlertmanagerAlert{alert}})[0]
+		if e.Resource.Application != "shop" {
+			t.Fatalf("expected the app label to win, got %s", e.Resource.Application)
+		}
+	}
+}
+
+func TestAlertmanagerReceiverErrors(t *testing.T) {
+	sent := 0
+	receiver := NewAlertmanagerReceiver(EventSinkFunc(func(ctx context.Context, e EvEvent) error {
+		if sent++; sent == 2 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}))
+
+	//the failure of the 2nd event asks for a retry of the whole group
+	w := httptest.NewRecorder()
+	receiver.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(alertmanagerPayload)))
+	if w.Code != http.StatusBadGateway || sent != 2 {
+		t.Fatalf("expected 502 after the 2nd event, got %d after %d events", w.Code, sent)
+	}
+
+	w = httptest.NewRecorder()
+	huge := `{"alerts": [{"annotations": {"dump": "` + strings.Repeat("x", 16*MaxPayloadBytes) + `"}}]}`
+	receiver.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(huge)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for an oversized body, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	receiver.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("{")))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid body, got %d", w.Code)
+	}
+}
@@ -1,13 +1,27 @@
 package waiops
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/go-resty/resty/v2"
 )
 
+const (
+	AlertsUri            = "/aiops/api/issue-resolution/v1/alerts"
+	IncidentsUri         = "/aiops/api/issue-resolution/v1/incidents"
+	EventsUri            = "/aiops/api/issue-resolution/v1/events"
+	MetricsUri           = "/aiops/api/app/metric-api/v1/metrics"
+	TopologyResourcesUri = "/aiops/topology/rest-observer/rest/resources"
+
+	defaultTenantID = "cfd95b7e-3bc7-4006-a4a8-a73a79c71255"
+)
+
 type API struct {
 	BaseUrl string
 
@@ -20,11 +34,17 @@ func NewAPI(baseUrl, apiUser, apiKey string) *API {
 	zenToken := base64.StdEncoding.EncodeToString([]byte(zenKey))
 	return &API{
 		zenToken: zenToken,
-		tenantID: "cfd95b7e-3bc7-4006-a4a8-a73a79c71255", //fixed as of now
+		tenantID: defaultTenantID, //fixed as of now
 		BaseUrl:  baseUrl,
 	}
 }
 
+// SetTenantID overrides the default tenant sent in the X-TenantID header
+func (a *API) SetTenantID(tenantID string) *API {
+	a.tenantID = tenantID
+	return a
+}
+
 func (a *API) CreateRequest() *resty.Request {
 	client := resty.New()
 	client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
@@ -38,29 +58,124 @@ func (a *API) CreateRequest() *resty.Request {
 	return request
 }
 
+// CallAPI sends the request with the given method. The first payload, if any, is used as the body.
 func (a *API) CallAPI(uri, method string, payloads ...any) (*resty.Response, error) {
-	request := a.CreateRequest()
-
-	var resp *resty.Response
-	var err error
-
-	switch method {
-	case "POST":
-		resp, err = request.SetBody(payloads[0]).Post(a.BaseUrl + uri)
-	case "GET":
-		resp, err = request.Get(a.BaseUrl + uri)
-	case "PATCH":
-		resp, err = request.SetBody(payloads[0]).Patch(a.BaseUrl + uri)
+	req := a.NewRequest(method, uri)
+	if len(payloads) > 0 {
+		req.SetBody(payloads[0])
+	}
+	return req.Do()
+}
+
+// Request builds a call against the API. Create it with API.NewRequest.
+type Request struct {
+	api     *API
+	ctx     context.Context
+	method  string
+	uri     string
+	query   map[string][]string
+	headers map[string]string
+	body    any
+}
+
+func (a *API) NewRequest(method, uri string) *Request {
+	return &Request{
+		api:     a,
+		method:  strings.ToUpper(method),
+		uri:     uri,
+		query:   map[string][]string{},
+		headers: map[string]string{},
+	}
+}
+
+func (r *Request) SetContext(ctx context.Context) *Request {
+	r.ctx = ctx
+	return r
+}
+
+// SetQueryParam replaces any existing value of the query parameter
+func (r *Request) SetQueryParam(key, value string) *Request {
+	r.query[key] = []string{value}
+	return r
+}
+
+// AddQueryParam appends a value, for parameters that can repeat
+func (r *Request) AddQueryParam(key, value string) *Request {
+	r.query[key] = append(r.query[key], value)
+	return r
+}
+
+func (r *Request) SetQueryParams(params map[string]string) *Request {
+	for k, v := range params {
+		r.SetQueryParam(k, v)
+	}
+	return r
+}
+
+// SetHeader overrides the default headers set by CreateRequest when the same key is used
+func (r *Request) SetHeader(key, value string) *Request {
+	r.headers[key] = value
+	return r
+}
+
+func (r *Request) SetBody(body any) *Request {
+	r.body = body
+	return r
+}
+
+func (r *Request) build() *resty.Request {
+	request := r.api.CreateRequest()
+	if r.ctx != nil {
+		request.SetContext(r.ctx)
+	}
+	for k, v := range r.headers {
+		request.SetHeader(k, v)
+	}
+	for k, values := range r.query {
+		for _, v := range values {
+			request.QueryParam.Add(k, v)
+		}
+	}
+	if r.body != nil {
+		request.SetBody(r.body)
+	}
+	return request
+}
+
+// Do executes the request. A status code of 300 or above is returned as an error together with the response.
+func (r *Request) Do() (*resty.Response, error) {
+	switch r.method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions:
 	default:
-		return nil, fmt.Errorf("unsupported method: %s", method)
+		return nil, fmt.Errorf("unsupported method: %s", r.method)
 	}
+
+	resp, err := r.build().Execute(r.method, r.api.BaseUrl+r.uri)
 	if err != nil {
 		return resp, err
 	}
 
 	if resp.StatusCode() >= 300 {
-		return resp, fmt.Errorf("non 200 status")
+		return resp, fmt.Errorf("non 200 status: %s", resp.Status())
 	}
 
 	return resp, nil
 }
+
+// DoAs executes the request and decodes the response body into T
+func DoAs[T any](r *Request) (T, error) {
+	var result T
+	resp, err := r.Do()
+	if err != nil {
+		return result, err
+	}
+
+	body := resp.Body()
+	if len(body) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, fmt.Errorf("failed to decode response of %s %s: %w", r.method, r.uri, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package waiops
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequest(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "a1", "severity": 5}`))
+	}))
+	defer server.Close()
+
+	api := NewAPI(server.URL, "admin", "secret").SetTenantID("t1")
+	alert, err := DoAs[EvAlert](api.NewRequest("patch", "/alerts").
+		SetQueryParam("filter", "old").
+		SetQueryParams(map[string]string{"filter": "state=open"}).
+		AddQueryParam("tag", "a").
+		AddQueryParam("tag", "b").
+		SetHeader("Accept", "text/plain").
+		SetBody(map[string]string{"state": "clear"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert.Id != "a1" || alert.Severity != 5 {
+		t.Fatalf("unexpected alert %+v", alert)
+	}
+
+	if got.Method != http.MethodPatch {
+		t.Fatalf("expect PATCH, got %s", got.Method)
+	}
+	query := got.URL.Query()
+	if query.Get("filter") != "state=open" || len(query["tag"]) != 2 {
+		t.Fatalf("unexpected query %v", query)
+	}
+	if got.Header.Get("X-TenantID") != "t1" || got.Header.Get("Accept") != "text/plain" {
+		t.Fatalf("unexpected headers %v", got.Header)
+	}
+	var sent map[string]string
+	if err := json.Unmarshal(body, &sent); err != nil || sent["state"] != "clear" {
+		t.Fatalf("unexpected body %s", body)
+	}
+
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE", "HEAD", "OPTIONS"} {
+		if _, err := api.NewRequest(method, "/alerts").Do(); err != nil || got.Method != method {
+			t.Fatalf("%s: %v, server got %s", method, err, got.Method)
+		}
+	}
+
+	resp, err := api.NewRequest("GET", "/missing").Do()
+	if err == nil || resp.StatusCode() != http.StatusNotFound {
+		t.Fatalf("expect the 404 as an error with its response, got %v", err)
+	}
+	if _, err := DoAs[EvAlert](api.NewRequest("GET", "/missing")); err == nil {
+		t.Fatal("expect DoAs to fail on 404")
+	}
+	if _, err := api.NewRequest("TRACE", "/alerts").Do(); err == nil {
+		t.Fatal("expect an error for an unsupported method")
+	}
+}
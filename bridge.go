@@ -0,0 +1,235 @@
+package waiops
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const (
+	BridgeEvents = "event"
+	BridgeAlerts = "alert"
+)
+
+// FileCheckpoint keeps the progress of a bridge as a small JSON file, rewritten atomically on every update
+type FileCheckpoint struct {
+	path string
+
+	mu    sync.Mutex
+	state map[string]string
+}
+
+func NewFileCheckpoint(path string) (*FileCheckpoint, error) {
+	c := &FileCheckpoint{path: path, state: map[string]string{}}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &c.state); err != nil {
+		return nil, fmt.Errorf("corrupted checkpoint %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *FileCheckpoint) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.state[key]
+	return v, ok
+}
+
+func (c *FileCheckpoint) Set(key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state[key] = value
+
+	content, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(c.path), "."+filepath.Base(c.path)+".tmp")
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// KafkaToREST consumes EvEvent or EvAlert records from a topic and posts them through the API, or sends them to the Sink.
+// The next offset of every partition is checkpointed after each successful post.
+type KafkaToREST struct {
+	Client     *kgo.Client //e.g. from NewSASL512Client, without any consume option
+	API        *API
+	Sink       Sink //overrides the API when set
+	Topic      string
+	Kind       string //BridgeEvents or BridgeAlerts
+	Checkpoint *FileCheckpoint
+}
+
+func (b *KafkaToREST) checkpointKey(partition int32) string {
+	return fmt.Sprintf("kafka:%s/%d", b.Topic, partition)
+}
+
+// Run forwards records until the context is done or a record fails to be forwarded.
+// Partitions without a checkpoint are read from the start. A record that cannot be decoded is logged and skipped.
+func (b *KafkaToREST) Run(ctx context.Context) error {
+	sink := b.Sink
+	if sink == nil {
+		sink = NewRESTSink(b.API)
+	}
+
+	details, err := AdminClient(b.Client).ListTopics(ctx, b.Topic)
+	if err != nil {
+		return err
+	}
+	detail, ok := details[b.Topic]
+	if !ok {
+		return fmt.Errorf("topic %s not found", b.Topic)
+	}
+	if detail.Err != nil {
+		return fmt.Errorf("failed to describe topic %s: %w", b.Topic, detail.Err)
+	}
+
+	offsets := map[int32]kgo.Offset{}
+	for p := range detail.Partitions {
+		offsets[p] = kgo.NewOffset().AtStart()
+		if v, ok := b.Checkpoint.Get(b.checkpointKey(p)); ok {
+			at, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid checkpoint for partition %d: %w", p, err)
+			}
+			offsets[p] = kgo.NewOffset().At(at)
+		}
+	}
+	b.Client.AddConsumePartitions(map[string]map[int32]kgo.Offset{b.Topic: offsets})
+	defer b.Client.PurgeTopicsFromConsuming(b.Topic)
+
+	for {
+		fetches := b.Client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return fmt.Errorf("failed to fetch %s[%d]: %w", errs[0].Topic, errs[0].Partition, errs[0].Err)
+		}
+
+		for iter := fetches.RecordIter(); !iter.Done(); {
+			r := iter.Next()
+			item, err := b.decode(r)
+			if err != nil {
+				log.Printf("Skipped %s[%d]@%d: %v", r.Topic, r.Partition, r.Offset, err)
+			} else if err := Send(ctx, sink, item); err != nil {
+				return fmt.Errorf("failed to forward %s[%d]@%d: %w", r.Topic, r.Partition, r.Offset, err)
+			}
+			if err := b.Checkpoint.Set(b.checkpointKey(r.Partition), strconv.FormatInt(r.Offset+1, 10)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *KafkaToREST) decode(r *kgo.Record) (any, error) {
+	if b.Kind == BridgeAlerts {
+		var alert EvAlert
+		err := json.Unmarshal(r.Value, &alert)
+		return alert, err
+	}
+
+	var event EvEvent
+	err := json.Unmarshal(r.Value, &event)
+	return event, err
+}
+
+// RESTToKafka polls the alerts of the API and publishes the ones that are new or changed since the last poll,
+// e.g. acknowledged or cleared, keyed by alert id. A hash of every alert published is checkpointed by id,
+// so the unchanged alerts are skipped after a restart too. The API has no change filter, every poll walks all the alerts.
+type RESTToKafka struct {
+	API        *API
+	Client     *kgo.Client
+	Topic      string
+	Interval   time.Duration
+	PageSize   int
+	Checkpoint *FileCheckpoint
+}
+
+const restToKafkaCheckpointKey = "rest:alerts.hashes"
+
+func (b *RESTToKafka) Run(ctx context.Context) error {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		count, err := b.PollOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Printf("Published %d alerts into %s", count, b.Topic)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollOnce walks all alerts once and publishes the new and changed ones
+func (b *RESTToKafka) PollOnce(ctx context.Context) (int, error) {
+	published := map[string]string{}
+	if v, ok := b.Checkpoint.Get(restToKafkaCheckpointKey); ok {
+		if err := json.Unmarshal([]byte(v), &published); err != nil {
+			return 0, fmt.Errorf("invalid checkpoint: %w", err)
+		}
+	}
+
+	current := map[string]string{}
+	records := []*kgo.Record{}
+	err := b.API.AlertPager(b.PageSize).Each(func(a EvAlert) bool {
+		value := a.AsJson()
+		sum := sha256.Sum256(value)
+		hash := hex.EncodeToString(sum[:16])
+		current[a.Id] = hash
+		if published[a.Id] != hash {
+			records = append(records, &kgo.Record{Topic: b.Topic, Key: []byte(a.Id), Value: value})
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	//same size and nothing to publish, the ids and hashes are the same as the checkpoint
+	if len(records) == 0 && len(current) == len(published) {
+		return 0, nil
+	}
+
+	if len(records) > 0 {
+		if err := b.Client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+			return 0, err
+		}
+	}
+	//the deleted alerts are dropped from the checkpoint
+	content, err := json.Marshal(current)
+	if err != nil {
+		return 0, err
+	}
+	return len(records), b.Checkpoint.Set(restToKafkaCheckpointKey, string(content))
+}
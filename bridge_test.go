@@ -0,0 +1,213 @@
+package waiops_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	. "github.com/zhiminwen/waiops"
+	"github.com/zhiminwen/waiops/kafkatest"
+)
+
+func TestKafkaToRESTResumesFromCheckpoint(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+
+	client, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	dir := t.TempDir()
+	produce := func() {
+		event := NewRandomEvent()
+		if err := client.ProduceSync(context.Background(), &kgo.Record{Topic: TopicLifecycleInputEvents, Value: event.AsJson()}).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(want int) {
+		checkpoint, err := NewFileCheckpoint(filepath.Join(dir, "checkpoint.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		consumer, err := kafka.Client("admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer consumer.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		bridge := &KafkaToREST{Client: consumer, API: server.API(), Topic: TopicLifecycleInputEvents, Kind: BridgeEvents, Checkpoint: checkpoint}
+		go func() { done <- bridge.Run(ctx) }()
+
+		deadline := time.Now().Add(10 * time.Second)
+		for len(server.Events()) < want && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if got := len(server.Events()); got != want {
+			t.Fatalf("expected %d events forwarded, got %d", want, got)
+		}
+	}
+
+	produce()
+	produce()
+	run(2)
+
+	produce()
+	run(3)
+}
+
+func TestKafkaToRESTSink(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	client, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	alert := NewRandomAlert()
+	if err := client.ProduceSync(context.Background(), &kgo.Record{Topic: TopicReplayAlerts, Value: alert.AsJson()}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint, err := NewFileCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := NewMemorySink()
+	bridge := &KafkaToREST{Client: client, Sink: sink, Topic: TopicReplayAlerts, Kind: BridgeAlerts, Checkpoint: checkpoint}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- bridge.Run(ctx) }()
+	for len(sink.Alerts()) < 1 && ctx.Err() == nil {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	//no API is set, the alert can only reach the sink
+	if alerts := sink.Alerts(); len(alerts) != 1 || alerts[0].Id != alert.Id || len(sink.Events()) != 0 {
+		t.Fatalf("expect the alert in the sink, got %+v", alerts)
+	}
+}
+
+func TestRESTToKafkaSkipsPublishedAlerts(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+
+	client, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := NewFileCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := &RESTToKafka{API: server.API(), Client: client, Topic: TopicRequestsAlerts, Checkpoint: checkpoint}
+
+	//the same last occurrence for both, a change of one must not hide the other
+	first, second := NewRandomAlert(), NewRandomAlert()
+	second.LastOccurrenceTime = first.LastOccurrenceTime
+	server.SeedAlerts(first)
+	if count, err := bridge.PollOnce(context.Background()); err != nil || count != 1 {
+		t.Fatalf("expected 1 alert published, got %d: %v", count, err)
+	}
+	server.SeedAlerts(second)
+	if count, err := bridge.PollOnce(context.Background()); err != nil || count != 1 {
+		t.Fatalf("expected the alert with the same last occurrence published, got %d: %v", count, err)
+	}
+	if count, err := bridge.PollOnce(context.Background()); err != nil || count != 0 {
+		t.Fatalf("expected nothing published again, got %d: %v", count, err)
+	}
+
+	//a state change keeps the last occurrence
+	if _, err := server.API().CallAPI(AlertsUri+"/"+first.Id, "PATCH", map[string]string{"state": "clear"}); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := bridge.PollOnce(context.Background()); err != nil || count != 1 {
+		t.Fatalf("expected the cleared alert published, got %d: %v", count, err)
+	}
+
+	//after a restart
+	checkpoint, err = NewFileCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge.Checkpoint = checkpoint
+	if count, err := bridge.PollOnce(context.Background()); err != nil || count != 0 {
+		t.Fatalf("expected nothing published after a restart, got %d: %v", count, err)
+	}
+
+	records := kafka.ExpectRecords(t, TopicRequestsAlerts, 3)
+	var cleared EvAlert
+	if err := json.Unmarshal(records[2].Value, &cleared); err != nil || cleared.Id != first.Id || cleared.State != "clear" {
+		t.Fatalf("expected the cleared alert last, got %s", records[2].Value)
+	}
+}
+
+func TestKafkaToRESTSkipsInvalidRecords(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+
+	client, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	checkpoint, err := NewFileCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bridge := &KafkaToREST{Client: client, API: server.API(), Topic: "missing", Kind: BridgeEvents, Checkpoint: checkpoint}
+	if err := bridge.Run(context.Background()); err == nil || strings.Contains(err.Error(), "<nil>") {
+		t.Fatalf("expected a meaningful error for a missing topic, got %v", err)
+	}
+
+	event := NewRandomEvent()
+	records := []*kgo.Record{
+		{Topic: TopicLifecycleInputEvents, Value: []byte("not json")},
+		{Topic: TopicLifecycleInputEvents, Value: event.AsJson()},
+	}
+	if err := client.ProduceSync(context.Background(), records...).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	bridge.Topic = TopicLifecycleInputEvents
+	done := make(chan error)
+	go func() { done <- bridge.Run(ctx) }()
+	for len(server.Events()) < 1 && ctx.Err() == nil {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if offset, _ := checkpoint.Get("kafka:" + TopicLifecycleInputEvents + "/0"); len(server.Events()) != 1 || offset != "2" {
+		t.Fatalf("expected the valid event forwarded past the invalid one, got %d events and offset %s", len(server.Events()), offset)
+	}
+}
@@ -0,0 +1,377 @@
+package waiops
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	CloudEventsContentType = "application/cloudevents+json"
+
+	CloudEventTypeEvent              = "com.ibm.aiops.event"
+	CloudEventTypeAlert              = "com.ibm.aiops.alert"
+	CloudEventTypeChangeNotification = "com.ibm.aiops.alert.change"
+
+	DefaultCloudEventSource = "/waiops"
+)
+
+type CloudEventMode int
+
+const (
+	// CloudEventStructured carries the attributes and the data in one application/cloudevents+json body
+	CloudEventStructured CloudEventMode = iota
+	// CloudEventBinary carries the attributes as ce-* HTTP or ce_* Kafka headers and the data as the body
+	CloudEventBinary
+)
+
+// CloudEvent is a CloudEvents 1.0 envelope. The data is kept encoded, JSON for the models of this package.
+type CloudEvent struct {
+	Id              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	DataSchema      string
+	Extensions      map[string]string
+	Data            []byte
+}
+
+// NewCloudEvent wraps an EvEvent, EvAlert or EvChangeNotification, or a pointer to one.
+// The source defaults to DefaultCloudEventSource, the subject is the resource name.
+func NewCloudEvent(v any, source string) (CloudEvent, error) {
+	if source == "" {
+		source = DefaultCloudEventSource
+	}
+	ce := CloudEvent{Source: source, DataContentType: "application/json"}
+
+	switch m := v.(type) {
+	case *EvEvent:
+		return NewCloudEvent(*m, source)
+	case *EvAlert:
+		return NewCloudEvent(*m, source)
+	case *EvChangeNotification:
+		return NewCloudEvent(*m, source)
+	case EvEvent:
+		ce.Id, ce.Type, ce.Subject, ce.Time = m.Id, CloudEventTypeEvent, m.Resource.Name, time.Time(m.OccurrenceTime)
+	case EvAlert:
+		ce.Id, ce.Type, ce.Subject, ce.Time = m.Id, CloudEventTypeAlert, m.Resource.Name, time.Time(m.LastOccurrenceTime)
+		if ce.Time.IsZero() {
+			ce.Time = time.Time(m.OccurrenceTime)
+		}
+	case EvChangeNotification:
+		ce.Id, ce.Type, ce.Subject, ce.Time = m.RequestId, CloudEventTypeChangeNotification, m.Entity.Resource.Name, time.Time(m.NotificationTime)
+		if m.Type != "" {
+			ce.Extensions = map[string]string{"changetype": strings.ToLower(m.Type)}
+		}
+	default:
+		return ce, fmt.Errorf("unsupported cloud event data %T", v)
+	}
+	if ce.Id == "" {
+		return ce, fmt.Errorf("the id is required by CloudEvents")
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ce, err
+	}
+	ce.Data = data
+	return ce, nil
+}
+
+// Model decodes the data into the model of the event type
+func (ce CloudEvent) Model(mode DecodeMode) (any, error) {
+	var v any
+	switch ce.Type {
+	case CloudEventTypeEvent:
+		v = &EvEvent{}
+	case CloudEventTypeAlert:
+		v = &EvAlert{}
+	case CloudEventTypeChangeNotification:
+		v = &EvChangeNotification{}
+	default:
+		return nil, fmt.Errorf("unsupported cloud event type %q", ce.Type)
+	}
+	if err := ce.DecodeData(v, mode); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// DecodeData decodes the JSON data into v
+func (ce CloudEvent) DecodeData(v any, mode DecodeMode) error {
+	if !isJSONContentType(ce.DataContentType) {
+		return fmt.Errorf("data of content type %q is not JSON", ce.DataContentType)
+	}
+	return Unmarshal(ce.Data, v, mode)
+}
+
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true //the default of the JSON format
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// attributes returns the context attributes, extensions included, formatted as strings
+func (ce CloudEvent) attributes() map[string]string {
+	attrs := map[string]string{}
+	for k, v := range ce.Extensions {
+		attrs[k] = v
+	}
+	attrs["specversion"] = CloudEventsSpecVersion
+	attrs["id"] = ce.Id
+	attrs["source"] = ce.Source
+	attrs["type"] = ce.Type
+	for k, v := range map[string]string{"subject": ce.Subject, "datacontenttype": ce.DataContentType, "dataschema": ce.DataSchema} {
+		if v != "" {
+			attrs[k] = v
+		}
+	}
+	if !ce.Time.IsZero() {
+		attrs["time"] = ce.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return attrs
+}
+
+// setAttribute is the reverse of attributes, the unknown names are kept as extensions
+func (ce *CloudEvent) setAttribute(name, value string) error {
+	switch name {
+	case "specversion":
+		if value != CloudEventsSpecVersion {
+			return fmt.Errorf("unsupported specversion %q", value)
+		}
+	case "id":
+		ce.Id = value
+	case "source":
+		ce.Source = value
+	case "type":
+		ce.Type = value
+	case "subject":
+		ce.Subject = value
+	case "datacontenttype":
+		ce.DataContentType = value
+	case "dataschema":
+		ce.DataSchema = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("invalid time: %w", err)
+		}
+		ce.Time = t
+	default:
+		if ce.Extensions == nil {
+			ce.Extensions = map[string]string{}
+		}
+		ce.Extensions[name] = value
+	}
+	return nil
+}
+
+func (ce *CloudEvent) validate() error {
+	missing := []string{}
+	for name, v := range map[string]string{"id": ce.Id, "source": ce.Source, "type": ce.Type} {
+		if v == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing required attribute(s) %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// MarshalJSON gives the structured JSON format. JSON data is embedded as is, other data as data_base64.
+func (ce CloudEvent) MarshalJSON() ([]byte, error) {
+	m := map[string]any{}
+	for k, v := range ce.attributes() {
+		m[k] = v
+	}
+	if ce.Data != nil {
+		if isJSONContentType(ce.DataContentType) {
+			m["data"] = json.RawMessage(ce.Data)
+		} else {
+			m["data_base64"] = base64.StdEncoding.EncodeToString(ce.Data)
+		}
+	}
+	return json.Marshal(m)
+}
+
+func (ce *CloudEvent) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	*ce = CloudEvent{}
+	if _, ok := m["specversion"]; !ok {
+		return fmt.Errorf("missing specversion")
+	}
+	for name, raw := range m {
+		switch name {
+		case "data":
+			ce.Data = raw
+		case "data_base64":
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("data_base64: %w", err)
+			}
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("data_base64: %w", err)
+			}
+			ce.Data = data
+		default:
+			//extensions may be booleans or integers, kept in their JSON form
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				s = string(raw)
+			}
+			if err := ce.setAttribute(name, s); err != nil {
+				return err
+			}
+		}
+	}
+	return ce.validate()
+}
+
+// WriteHTTP sets the headers and returns the body of the event in the given mode
+func (ce CloudEvent) WriteHTTP(header http.Header, mode CloudEventMode) ([]byte, error) {
+	if mode == CloudEventStructured {
+		header.Set("Content-Type", CloudEventsContentType)
+		return json.Marshal(ce)
+	}
+
+	for name, v := range ce.attributes() {
+		if name == "datacontenttype" {
+			header.Set("Content-Type", v)
+			continue
+		}
+		header.Set("ce-"+name, percentEncode(v))
+	}
+	return ce.Data, nil
+}
+
+// CloudEventFromHTTP reads an event of either mode, told apart by the content type
+func CloudEventFromHTTP(header http.Header, body []byte) (CloudEvent, error) {
+	var ce CloudEvent
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType == CloudEventsContentType {
+		err := json.Unmarshal(body, &ce)
+		return ce, err
+	}
+
+	if header.Get("ce-specversion") == "" {
+		return ce, fmt.Errorf("not a cloud event, neither %s nor ce-specversion", CloudEventsContentType)
+	}
+	for key, values := range header {
+		name, ok := strings.CutPrefix(strings.ToLower(key), "ce-")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		v, err := url.PathUnescape(values[0])
+		if err != nil {
+			return ce, fmt.Errorf("header %s: %w", key, err)
+		}
+		if err := ce.setAttribute(name, v); err != nil {
+			return ce, err
+		}
+	}
+	ce.DataContentType = header.Get("Content-Type")
+	ce.Data = body
+	return ce, ce.validate()
+}
+
+// percentEncode escapes what the HTTP binding requires: space, double quote, percent and anything beyond printable ASCII
+func percentEncode(s string) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		if b <= ' ' || b >= 0x7f || b == '"' || b == '%' {
+			fmt.Fprintf(&sb, "%%%02X", b)
+			continue
+		}
+		sb.WriteByte(b)
+	}
+	return sb.String()
+}
+
+// KafkaRecord builds the record of the event in the given mode, keyed by the partitionkey extension or else the id
+func (ce CloudEvent) KafkaRecord(topic string, mode CloudEventMode) (*kgo.Record, error) {
+	key := ce.Extensions["partitionkey"]
+	if key == "" {
+		key = ce.Id
+	}
+	r := &kgo.Record{Topic: topic, Key: []byte(key)}
+
+	if mode == CloudEventStructured {
+		value, err := json.Marshal(ce)
+		if err != nil {
+			return nil, err
+		}
+		r.Value = value
+		r.Headers = []kgo.RecordHeader{{Key: "content-type", Value: []byte(CloudEventsContentType)}}
+		return r, nil
+	}
+
+	attrs := ce.attributes()
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := "ce_" + name
+		if name == "datacontenttype" {
+			key = "content-type"
+		}
+		r.Headers = append(r.Headers, kgo.RecordHeader{Key: key, Value: []byte(attrs[name])})
+	}
+	r.Value = ce.Data
+	return r, nil
+}
+
+// CloudEventFromKafka reads an event of either mode, told apart by the content-type header
+func CloudEventFromKafka(r *kgo.Record) (CloudEvent, error) {
+	var ce CloudEvent
+	headers := map[string]string{}
+	for _, h := range r.Headers {
+		headers[strings.ToLower(h.Key)] = string(h.Value)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(headers["content-type"])
+	if mediaType == CloudEventsContentType {
+		err := json.Unmarshal(r.Value, &ce)
+		return ce, err
+	}
+
+	if headers["ce_specversion"] == "" {
+		return ce, fmt.Errorf("not a cloud event, neither %s nor ce_specversion", CloudEventsContentType)
+	}
+	for key, v := range headers {
+		name, ok := strings.CutPrefix(key, "ce_")
+		if !ok {
+			continue
+		}
+		if err := ce.setAttribute(name, v); err != nil {
+			return ce, err
+		}
+	}
+	ce.DataContentType = headers["content-type"]
+	ce.Data = r.Value
+	return ce, ce.validate()
+}
@@ -0,0 +1,100 @@
+package waiops
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCloudEventHTTP(t *testing.T) {
+	event := NewRandomEvent()
+	event.Resource.Name = "web server 1" //percent encoded in the binary mode
+	event.SetOccurrenceTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+
+	ce, err := NewCloudEvent(&event, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []CloudEventMode{CloudEventStructured, CloudEventBinary} {
+		header := http.Header{}
+		body, err := ce.WriteHTTP(header, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode == CloudEventBinary && header.Get("ce-subject") != "web%20server%201" {
+			t.Fatalf("unexpected ce-subject %q", header.Get("ce-subject"))
+		}
+
+		got, err := CloudEventFromHTTP(header, body)
+		if err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		if got.Id != event.Id || got.Type != CloudEventTypeEvent || got.Source != DefaultCloudEventSource ||
+			got.Subject != "web server 1" || !got.Time.Equal(time.Time(event.OccurrenceTime)) {
+			t.Fatalf("mode %d: unexpected attributes %+v", mode, got)
+		}
+
+		model, err := got.Model(DecodeStrict)
+		if err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		decoded, ok := model.(*EvEvent)
+		if !ok || decoded.Summary != event.Summary || decoded.Resource.Name != "web server 1" {
+			t.Fatalf("mode %d: unexpected data %+v", mode, model)
+		}
+	}
+}
+
+func TestCloudEventKafka(t *testing.T) {
+	alert := NewRandomAlert()
+	notification := EvChangeNotification{
+		RequestId:        "req-1",
+		NotificationTime: EvTime(time.Now()),
+		Type:             "Create",
+		EntityType:       "alert",
+		Entity:           alert,
+	}
+
+	for _, v := range []any{alert, notification} {
+		ce, err := NewCloudEvent(v, "/tests")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mode := range []CloudEventMode{CloudEventStructured, CloudEventBinary} {
+			r, err := ce.KafkaRecord("events", mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := CloudEventFromKafka(r)
+			if err != nil {
+				t.Fatalf("%s mode %d: %v", ce.Type, mode, err)
+			}
+			if got.Id != ce.Id || got.Type != ce.Type || got.Source != "/tests" || got.Extensions["changetype"] != ce.Extensions["changetype"] {
+				t.Fatalf("%s mode %d: unexpected attributes %+v", ce.Type, mode, got)
+			}
+			if _, err := got.Model(DecodeStrict); err != nil {
+				t.Fatalf("%s mode %d: %v", ce.Type, mode, err)
+			}
+		}
+	}
+}
+
+func TestCloudEventStructuredJSON(t *testing.T) {
+	content := []byte(`{"specversion":"1.0","id":"1","source":"/x","type":"other","comexampleflag":true,"data_base64":"aGVsbG8=","datacontenttype":"text/plain"}`)
+	var ce CloudEvent
+	if err := json.Unmarshal(content, &ce); err != nil {
+		t.Fatal(err)
+	}
+	if string(ce.Data) != "hello" || ce.Extensions["comexampleflag"] != "true" {
+		t.Fatalf("unexpected event %+v", ce)
+	}
+	if _, err := ce.Model(DecodeLenient); err == nil {
+		t.Fatal("expected an error for an unknown type")
+	}
+
+	if err := json.Unmarshal([]byte(`{"specversion":"1.0","id":"1"}`), &ce); err == nil {
+		t.Fatal("expected an error for the missing source and type")
+	}
+}
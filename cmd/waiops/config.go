@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/zhiminwen/waiops"
+)
+
+func defaultConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".waiops.yaml"
+	}
+	return filepath.Join(home, ".waiops.yaml")
+}
+
+func loadProfile(file, name string) (waiops.Profile, error) {
+	config, err := waiops.LoadConfig(file)
+	if err != nil {
+		return waiops.Profile{}, err
+	}
+	return config.Profile(name)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zhiminwen/waiops"
+)
+
+const usage = `waiops <command> [flags]
+
+Commands:
+  gen event|alert        print random events or alerts
+  send event|alert       send random events or alerts through REST, kafka or into a file
+  alerts list|patch      list alerts, or patch one with key=value pairs
+  incidents list|patch   list incidents, or patch one with key=value pairs
+  topics                 list the AIOps kafka topics with partitions, retention and lag
+  schema <type>          print the JSON Schema of EvEvent, EvAlert, EvResource, Incident, MetricGroup or Vertex
+  netcool import|export  convert an alerts.status CSV export to alerts as JSON lines, or back
+  mapping <file> <doc>   test an event mapping against a JSON document, showing the event and the failed expressions
+
+Connection settings are read from a profile of -config (default ~/.waiops.yaml), selected by -profile,
+and overridden by the WAIOPS_* environment variables.
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "gen":
+		err = genCmd(os.Args[2:])
+	case "send":
+		err = sendCmd(os.Args[2:])
+	case "alerts":
+		err = resourceCmd(os.Args[2:], waiops.AlertsUri, "alerts")
+	case "incidents":
+		err = resourceCmd(os.Args[2:], waiops.IncidentsUri, "incidents")
+	case "topics":
+		err = topicsCmd(os.Args[2:])
+	case "schema":
+		err = schemaCmd(os.Args[2:])
+	case "netcool":
+		err = netcoolCmd(os.Args[2:])
+	case "mapping":
+		err = mappingCmd(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type configFlags struct {
+	file    *string
+	profile *string
+}
+
+func (c configFlags) load() (waiops.Profile, error) {
+	return loadProfile(*c.file, *c.profile)
+}
+
+func newFlagSet(name string) (*flag.FlagSet, configFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return fs, configFlags{
+		file:    fs.String("config", defaultConfigFile(), "config file, YAML or JSON"),
+		profile: fs.String("profile", "", "profile of the config file, defaults to WAIOPS_PROFILE or the default profile"),
+	}
+}
+
+// parseArgs parses the flags wherever they are among the positional arguments, e.g. send event -n 3,
+// and returns the positional ones. Everything after -- is positional.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func kindArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expect event or alert, got %q", args)
+	}
+	kind := args[0]
+	if kind != "event" && kind != "alert" {
+		return "", fmt.Errorf("expect event or alert, got %q", kind)
+	}
+	return kind, nil
+}
+
+type validatable interface {
+	Validate() error
+}
+
+func randomItem(kind string) (string, validatable) {
+	if kind == "alert" {
+		alert := waiops.NewRandomAlert()
+		return alert.Id, &alert
+	}
+	event := waiops.NewRandomEvent()
+	return event.Id, &event
+}
+
+func genCmd(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	count := fs.Int("n", 1, "number of items")
+
+	kind, err := kindArg(parseArgs(fs, args))
+	if err != nil {
+		return err
+	}
+	for i := 0; i < *count; i++ {
+		_, item := randomItem(kind)
+		payload, err := json.MarshalIndent(item, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(payload))
+	}
+	return nil
+}
+
+func sendCmd(args []string) error {
+	fs, configFlags := newFlagSet("send")
+	count := fs.Int("n", 1, "number of items")
+	via := fs.String("via", "rest", "rest, kafka[:topic], file:<path> or stdout, comma separated for several")
+	topic := fs.String("topic", "", "kafka topic, defaults to the lifecycle events or replay alerts topic")
+
+	kind, err := kindArg(parseArgs(fs, args))
+	if err != nil {
+		return err
+	}
+	profile, err := configFlags.load()
+	if err != nil {
+		return err
+	}
+
+	spec := *via
+	if spec == "kafka" && *topic != "" {
+		spec = "kafka:" + *topic
+	}
+	sink, err := profile.OpenSink(spec)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+
+	for i := 0; i < *count; i++ {
+		id, item := randomItem(kind)
+		if err := item.Validate(); err != nil {
+			return fmt.Errorf("invalid %s %s: %w", kind, id, err)
+		}
+		if err := waiops.Send(context.Background(), sink, item); err != nil {
+			return fmt.Errorf("failed to send %s %s: %w", kind, id, err)
+		}
+		log.Printf("Sent %s %s", kind, id)
+	}
+	return nil
+}
+
+func resourceCmd(args []string, uri, itemsKey string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expect list or patch")
+	}
+	fs, configFlags := newFlagSet(itemsKey)
+	limit := fs.Int("limit", 50, "maximum number of items to list, 0 for all")
+	positional := parseArgs(fs, args[1:])
+
+	profile, err := configFlags.load()
+	if err != nil {
+		return err
+	}
+	api, err := profile.API()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		if len(positional) > 0 {
+			return fmt.Errorf("unexpected arguments %q", positional)
+		}
+		count := 0
+		enc := json.NewEncoder(os.Stdout)
+		pageSize := 100
+		if *limit > 0 {
+			pageSize = min(*limit, pageSize)
+		}
+		err := waiops.NewPager[json.RawMessage](api, uri, itemsKey, pageSize).Each(func(item json.RawMessage) bool {
+			enc.Encode(item)
+			count++
+			return *limit <= 0 || count < *limit
+		})
+		return err
+
+	case "patch":
+		if len(positional) < 2 {
+			return fmt.Errorf("usage: %s patch <id> key=value...", itemsKey)
+		}
+		patch := map[string]any{}
+		for _, kv := range positional[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return fmt.Errorf("expect key=value, got %q", kv)
+			}
+			// values are taken as JSON when they parse, e.g. true or 3, otherwise as strings
+			var value any
+			if err := json.Unmarshal([]byte(v), &value); err != nil {
+				value = v
+			}
+			patch[k] = value
+		}
+		resp, err := api.CallAPI(uri+"/"+positional[0], "PATCH", patch)
+		if err != nil {
+			return err
+		}
+		fmt.Println(resp.String())
+		return nil
+	}
+	return fmt.Errorf("unsupported action %s", args[0])
+}
+
+func topicsCmd(args []string) error {
+	fs, configFlags := newFlagSet("topics")
+	if positional := parseArgs(fs, args); len(positional) > 0 {
+		return fmt.Errorf("unexpected arguments %q", positional)
+	}
+
+	profile, err := configFlags.load()
+	if err != nil {
+		return err
+	}
+	client, err := profile.KafkaClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	summaries, err := waiops.ListAIOpsTopics(context.Background(), waiops.AdminClient(client))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TOPIC\tPARTITIONS\tRETENTION(ms)\tLAG")
+	for _, s := range summaries {
+		lags := []string{}
+		for group, lag := range s.Lag {
+			lags = append(lags, fmt.Sprintf("%s=%d", group, lag))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", s.Topic, s.Partitions, s.RetentionMs, strings.Join(lags, ","))
+	}
+	return w.Flush()
+}
+
+func schemaCmd(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: schema <type>")
+	}
+	v, ok := waiops.SchemaTypes[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown type %s", args[0])
+	}
+	content, err := waiops.JSONSchema(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(content))
+	return nil
+}
+
+func netcoolCmd(args []string) error {
+	fs := flag.NewFlagSet("netcool", flag.ExitOnError)
+	mappingFile := fs.String("mapping", "", "JSON file of the column to alert field mapping, defaults to the built-in mapping")
+	positional := parseArgs(fs, args)
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: netcool import <file.csv> | export <file.jsonl>")
+	}
+
+	mapping := waiops.DefaultNetcoolMapping
+	if *mappingFile != "" {
+		content, err := os.ReadFile(*mappingFile)
+		if err != nil {
+			return err
+		}
+		mapping = waiops.NetcoolMapping{}
+		if err := json.Unmarshal(content, &mapping); err != nil {
+			return err
+		}
+		if err := mapping.Validate(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(positional[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch positional[0] {
+	case "import":
+		enc := json.NewEncoder(os.Stdout)
+		return waiops.ReadNetcoolCSV(f, mapping, func(a waiops.EvAlert) error {
+			return enc.Encode(a)
+		})
+	case "export":
+		alerts := []waiops.EvAlert{}
+		dec := json.NewDecoder(f)
+		for dec.More() {
+			var a waiops.EvAlert
+			if err := dec.Decode(&a); err != nil {
+				return err
+			}
+			alerts = append(alerts, a)
+		}
+		return waiops.WriteNetcoolCSV(os.Stdout, mapping, alerts)
+	}
+	return fmt.Errorf("expect import or export, got %q", positional[0])
+}
+
+func mappingCmd(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: mapping <mapping.yaml> <document.json>")
+	}
+	//the expression errors are reported with the fields of the test
+	mapping, err := waiops.LoadEventMapping(args[0])
+	if mapping == nil {
+		return err
+	}
+	doc, err := os.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	result := mapping.Test(doc)
+	if err := result.Report(os.Stdout); err != nil {
+		return err
+	}
+	if len(result.Failed()) > 0 || len(result.Violations) > 0 {
+		return fmt.Errorf("%d failed field(s), %d violation(s)", len(result.Failed()), len(result.Violations))
+	}
+	return nil
+}
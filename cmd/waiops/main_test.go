@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	count := fs.Int("n", 1, "")
+	via := fs.String("via", "rest", "")
+
+	positional := parseArgs(fs, []string{"event", "-n", "3", "-via", "kafka", "--", "-x"})
+	if *count != 3 || *via != "kafka" {
+		t.Fatalf("expect the flags after the kind parsed, got n=%d via=%s", *count, *via)
+	}
+	if len(positional) != 2 || positional[0] != "event" || positional[1] != "-x" {
+		t.Fatalf("unexpected positional arguments %q", positional)
+	}
+}
+
+func TestSendCmd(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "alerts.jsonl")
+	args := []string{"alert", "-n", "3", "-via", "file:" + file, "-config", filepath.Join(dir, "missing.yaml")}
+	if err := sendCmd(args); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+		var alert map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &alert); err != nil || alert["deduplicationKey"] == nil {
+			t.Fatalf("expect an alert, got %s", scanner.Text())
+		}
+	}
+	if lines != 3 {
+		t.Fatalf("expect 3 alerts, got %d", lines)
+	}
+
+	if err := sendCmd([]string{"alert", "event", "-config", filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Fatal("expect an error for two kinds")
+	}
+}
@@ -0,0 +1,175 @@
+package waiops
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is a set of named profiles, loaded from a YAML or JSON file
+//
+//	defaultProfile: dev
+//	profiles:
+//	  dev:
+//	    apiUrl: https://cpd-cp4aiops.apps.example.com
+//	    apiUser: admin
+//	    apiKey: xxx
+//	    kafka:
+//	      brokers: [kafka.example.com:443]
+//	      user: cp4waiops-cartridge-kafka-auth
+//	      password: xxx
+//	      caCert: /path/to/ca.pem
+//
+// The credentials can be references resolved by the SecretResolver, e.g. apiKey: k8s:cp4aiops/aiops-api#key
+type Config struct {
+	DefaultProfile string             `json:"defaultProfile"`
+	Profiles       map[string]Profile `json:"profiles"`
+
+	Secrets *SecretResolver `json:"-"` //the default resolver is used when nil
+}
+
+type Profile struct {
+	ApiUrl   string `json:"apiUrl"`
+	ApiUser  string `json:"apiUser"`
+	ApiKey   string `json:"apiKey"`
+	TenantID string `json:"tenantId"`
+
+	Kafka KafkaProfile `json:"kafka"`
+}
+
+type KafkaProfile struct {
+	Brokers            []string `json:"brokers"`
+	SASLMechanism      string   `json:"saslMechanism"` //defaults to SCRAM-SHA-512 when a user is set, "none" to disable
+	User               string   `json:"user"`
+	Password           string   `json:"password"`
+	TLS                *bool    `json:"tls"` //defaults to true
+	InsecureSkipVerify bool     `json:"insecureSkipVerify"`
+	CACert             string   `json:"caCert"` //file path
+	CAPem              string   `json:"caPem"`
+	ClientCert         string   `json:"clientCert"` //file path, for mTLS
+	ClientKey          string   `json:"clientKey"`
+}
+
+// LoadConfig reads the config file. A missing file gives an empty config, so the environment alone can be used.
+func LoadConfig(file string) (*Config, error) {
+	config := &Config{Profiles: map[string]Profile{}}
+
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+	return config, nil
+}
+
+// Profile returns the named profile with the WAIOPS_* environment variables applied on top and the credentials resolved.
+// An empty name falls back to WAIOPS_PROFILE, then to the default profile.
+func (c *Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv("WAIOPS_PROFILE")
+	}
+	if name == "" {
+		name = c.DefaultProfile
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok && name != "" {
+		names := []string{}
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return profile, fmt.Errorf("profile %s not found, available: %s", name, strings.Join(names, ", "))
+	}
+
+	profile.applyEnv()
+
+	resolver := c.Secrets
+	if resolver == nil {
+		resolver = defaultSecretResolver
+	}
+	for _, field := range []*string{
+		&profile.ApiUser,
+		&profile.ApiKey,
+		&profile.Kafka.User,
+		&profile.Kafka.Password,
+		&profile.Kafka.CAPem,
+	} {
+		v, err := resolver.Resolve(*field)
+		if err != nil {
+			return profile, fmt.Errorf("profile %s: %w", name, err)
+		}
+		*field = v
+	}
+	return profile, nil
+}
+
+func (p *Profile) applyEnv() {
+	for env, field := range map[string]*string{
+		"WAIOPS_API_URL":              &p.ApiUrl,
+		"WAIOPS_API_USER":             &p.ApiUser,
+		"WAIOPS_API_KEY":              &p.ApiKey,
+		"WAIOPS_TENANT_ID":            &p.TenantID,
+		"WAIOPS_KAFKA_SASL_MECHANISM": &p.Kafka.SASLMechanism,
+		"WAIOPS_KAFKA_USER":           &p.Kafka.User,
+		"WAIOPS_KAFKA_PASSWORD":       &p.Kafka.Password,
+		"WAIOPS_KAFKA_CA":             &p.Kafka.CACert,
+	} {
+		if v, ok := os.LookupEnv(env); ok {
+			*field = v
+		}
+	}
+	if v, ok := os.LookupEnv("WAIOPS_KAFKA_BROKERS"); ok {
+		p.Kafka.Brokers = strings.Split(v, ",")
+	}
+}
+
+func (p Profile) API() (*API, error) {
+	if p.ApiUrl == "" || p.ApiUser == "" || p.ApiKey == "" {
+		return nil, fmt.Errorf("apiUrl, apiUser and apiKey are required")
+	}
+	api := NewAPI(p.ApiUrl, p.ApiUser, p.ApiKey)
+	if p.TenantID != "" {
+		api.SetTenantID(p.TenantID)
+	}
+	return api, nil
+}
+
+func (p Profile) KafkaConfig() KafkaConfig {
+	k := p.Kafka
+	config := KafkaConfig{
+		Brokers:            k.Brokers,
+		SASLMechanism:      k.SASLMechanism,
+		User:               k.User,
+		Password:           k.Password,
+		TLS:                k.TLS == nil || *k.TLS,
+		InsecureSkipVerify: k.InsecureSkipVerify,
+		CAFile:             k.CACert,
+		CAPem:              []byte(k.CAPem),
+		CertFile:           k.ClientCert,
+		KeyFile:            k.ClientKey,
+	}
+	switch {
+	case strings.EqualFold(config.SASLMechanism, "none"):
+		config.SASLMechanism = SASLNone
+	case config.SASLMechanism == "" && k.User != "":
+		config.SASLMechanism = SASLScramSha512
+	}
+	return config
+}
+
+func (p Profile) KafkaClient(opts ...kgo.Opt) (*kgo.Client, error) {
+	return NewKafkaClient(p.KafkaConfig(), opts...)
+}
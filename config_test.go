@@ -0,0 +1,55 @@
+package waiops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigProfileWithEnvOverrides(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "waiops.yaml")
+	content := `
+defaultProfile: dev
+profiles:
+  dev:
+    apiUrl: https://dev.example.com
+    apiUser: admin
+    apiKey: dev-key
+    kafka:
+      brokers: [dev:9092]
+      user: dev
+      password: dev-pass
+      tls: false
+  prod:
+    apiUrl: https://prod.example.com
+`
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("WAIOPS_API_KEY", "env-key")
+	profile, err := config.Profile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.ApiUrl != "https://dev.example.com" || profile.ApiKey != "env-key" {
+		t.Fatalf("expected the dev profile with the env api key, got %+v", profile)
+	}
+
+	kafka := profile.KafkaConfig()
+	if kafka.SASLMechanism != SASLScramSha512 || kafka.TLS {
+		t.Fatalf("expected plaintext SCRAM-SHA-512, got %+v", kafka)
+	}
+	if err := kafka.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := config.Profile("staging"); err == nil {
+		t.Fatal("expected an error for an unknown profile")
+	}
+}
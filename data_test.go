@@ -2,7 +2,9 @@ package waiops
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dsnet/try"
 )
@@ -98,3 +100,142 @@ func TestUpdateDedupKeyAndSignatureIgnoresExtras(t *testing.T) {
 		t.Fatalf("expected signature %q, got %q", expected, alert.Signature)
 	}
 }
+
+func TestEvResourceUnmarshalJSONDecodesPort(t *testing.T) {
+	for content, want := range map[string]int{
+		`{"port":8080}`:   8080,
+		`{"port":"8443"}`: 8443,
+		`{"port":null}`:   0,
+	} {
+		var res EvResource
+		if err := json.Unmarshal([]byte(content), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Port != want {
+			t.Fatalf("%s: expected port %d, got %d", content, want, res.Port)
+		}
+		if _, ok := res.Extras["port"]; ok {
+			t.Fatalf("%s: expected port not to be kept in extras", content)
+		}
+	}
+}
+
+func TestEvResourceUnmarshalJSONKeepsMistypedFieldsInExtras(t *testing.T) {
+	content := []byte(`{"name":"node1","hostname":42,"port":"http"}`)
+
+	var res EvResource
+	if err := json.Unmarshal(content, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Hostname != "" || res.Extras["hostname"] != float64(42) {
+		t.Fatalf("expected hostname kept in extras, got %q and %v", res.Hostname, res.Extras["hostname"])
+	}
+	if res.Port != 0 || res.Extras["port"] != "http" {
+		t.Fatalf("expected port kept in extras, got %d and %v", res.Port, res.Extras["port"])
+	}
+
+	// the original values survive a round trip
+	var payload map[string]any
+	if err := json.Unmarshal(try.E1(json.Marshal(res)), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["hostname"] != float64(42) || payload["port"] != "http" {
+		t.Fatalf("expected original values after round trip, got %v", payload)
+	}
+}
+
+func TestEvResourceUnmarshalStrictJSONReportsMismatches(t *testing.T) {
+	var res EvResource
+	err := res.UnmarshalStrictJSON([]byte(`{"name":"node1","hostname":42,"port":70000}`))
+	if err == nil {
+		t.Fatal("expected an error in strict mode")
+	}
+	for _, want := range []string{"hostname", "port"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s in %v", want, err)
+		}
+	}
+}
+
+func TestEvTimeUnmarshalJSONFormats(t *testing.T) {
+	want := time.Date(2023, 8, 23, 20, 41, 12, 420000000, time.UTC)
+	for _, content := range []string{
+		`"2023-08-23T20:41:12.420Z"`,
+		`"2023-08-23T20:41:12.42Z"`,
+		`"2023-08-24T04:41:12.420+08:00"`,
+		`1692823272420`,
+		`"1692823272420"`,
+		`1692823272.42`,
+	} {
+		var got EvTime
+		if err := json.Unmarshal([]byte(content), &got); err != nil {
+			t.Fatalf("%s: %v", content, err)
+		}
+		if !time.Time(got).Equal(want) {
+			t.Fatalf("%s: expected %v, got %v", content, want, time.Time(got))
+		}
+	}
+
+	var got EvTime
+	if err := json.Unmarshal([]byte(`null`), &got); err != nil || !time.Time(got).IsZero() {
+		t.Fatalf("expected null to give the zero time, got %v: %v", time.Time(got), err)
+	}
+	if err := json.Unmarshal([]byte(`"yesterday"`), &got); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
+
+func TestUnmarshalStrictRejectsUnknownFields(t *testing.T) {
+	content := []byte(`{"id":"a1","summary":"down","severity":3,"customField":"x"}`)
+
+	var alert EvAlert
+	if err := Unmarshal(content, &alert, DecodeLenient); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(content, &alert, DecodeStrict); err == nil {
+		t.Fatal("expected the unknown field to be rejected")
+	}
+
+	content = []byte(`{"id":"e1","resource":{"name":"node1","hostname":42}}`)
+	var event EvEvent
+	if err := Unmarshal(content, &event, DecodeStrict); err == nil {
+		t.Fatal("expected the mistyped resource field to be rejected")
+	}
+
+	content = []byte(`{"id":"e1","type":{"eventType":"problem","customField":"x"}}`)
+	if err := Unmarshal(content, &event, DecodeStrict); err == nil {
+		t.Fatal("expected the unknown nested field to be rejected")
+	}
+
+	content = []byte(`{"id":"e1","resource":{"name":"node1","rack":"r1"}}`)
+	if err := Unmarshal(content, &event, DecodeStrict); err != nil || event.Resource.Extras["rack"] != "r1" {
+		t.Fatalf("expected the resource extras to be kept, got %v", err)
+	}
+}
+
+func TestUnmarshalStrictRejectsEpochTimes(t *testing.T) {
+	for _, content := range []string{
+		`{"id":"e1","occurrenceTime":1692823272420}`,
+		`{"id":"e1","occurrenceTime":"1692823272"}`,
+	} {
+		var event EvEvent
+		if err := Unmarshal([]byte(content), &event, DecodeLenient); err != nil {
+			t.Fatalf("%s: %v", content, err)
+		}
+		if err := Unmarshal([]byte(content), &event, DecodeStrict); err == nil || !strings.Contains(err.Error(), "occurrenceTime") {
+			t.Fatalf("%s: expected the time to be rejected, got %v", content, err)
+		}
+	}
+
+	content := []byte(`{"type":"create","notificationTime":"2023-08-24T04:41:12+08:00","entity":{"id":"a1","firstOccurrenceTime":1692823272}}`)
+	var n EvChangeNotification
+	if err := Unmarshal(content, &n, DecodeStrict); err == nil || !strings.Contains(err.Error(), "entity: firstOccurrenceTime") {
+		t.Fatalf("expected the nested epoch time to be rejected, got %v", err)
+	}
+
+	content = []byte(`{"id":"e1","occurrenceTime":"2023-08-23T20:41:12.420Z"}`)
+	var event EvEvent
+	if err := Unmarshal(content, &event, DecodeStrict); err != nil {
+		t.Fatal(err)
+	}
+}
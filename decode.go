@@ -0,0 +1,130 @@
+package waiops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type DecodeMode int
+
+const (
+	// DecodeLenient is the plain json.Unmarshal behaviour: unknown fields are ignored
+	// and mistyped resource fields are kept in Extras
+	DecodeLenient DecodeMode = iota
+	// DecodeStrict rejects unknown fields, nested ones included, mistyped resource fields and times not in RFC3339.
+	// Any key of a resource that is not a known field is still kept in its Extras, the resource is open by design.
+	DecodeStrict
+)
+
+// Unmarshal decodes any of the model types in the given mode
+func Unmarshal(data []byte, v any, mode DecodeMode) error {
+	if mode == DecodeLenient {
+		return json.Unmarshal(data, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after the top level value")
+	}
+	if err := strictTimes(data, reflect.TypeOf(v)); err != nil {
+		return err
+	}
+
+	// EvResource decodes itself with its open Extras, its strict decoding is run separately
+	switch v.(type) {
+	case *EvEvent, *EvAlert:
+		return strictResources(data)
+	case *EvChangeNotification:
+		var n struct {
+			Entity json.RawMessage `json:"entity"`
+		}
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		if len(n.Entity) == 0 {
+			return nil
+		}
+		return strictResources(n.Entity)
+	}
+	return nil
+}
+
+func strictResources(data []byte) error {
+	var raw struct {
+		Sender   json.RawMessage `json:"sender"`
+		Resource json.RawMessage `json:"resource"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for name, content := range map[string]json.RawMessage{"sender": raw.Sender, "resource": raw.Resource} {
+		if len(content) == 0 || string(content) == "null" {
+			continue
+		}
+		var r EvResource
+		if err := r.UnmarshalStrictJSON(content); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// strictTimes walks the JSON value of type t and fails on the EvTime values that are not RFC3339 strings,
+// e.g. the epoch times accepted by the lenient decoding. The type errors are left to the main decoding.
+func strictTimes(data []byte, t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(EvTime{}):
+		if string(bytes.TrimSpace(data)) == "null" {
+			return nil
+		}
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("expect an RFC3339 time, got %s", data)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			return fmt.Errorf("expect an RFC3339 time, got %q", s)
+		}
+
+	case t.Kind() == reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil
+		}
+		for i, item := range items {
+			if err := strictTimes(item, t.Elem()); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+
+	case t.Kind() == reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			raw, ok := fields[name]
+			if !field.IsExported() || name == "" || name == "-" || !ok {
+				continue
+			}
+			if err := strictTimes(raw, field.Type); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package waiops
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// the Extras keys recording the enrichment
+const (
+	EnrichedFieldsKey = "enrichedFields" //comma separated names of the fields filled
+	EnrichedByKey     = "enrichedBy"     //comma separated names of the lookups used
+)
+
+// ResourceLookup finds the resource fields, keyed by their JSON name, of a hostname, IP address or name
+type ResourceLookup interface {
+	LookupResource(key string) (map[string]string, bool)
+}
+
+// LookupTable is a static table of resource fields, the keys are matched case insensitively
+type LookupTable struct {
+	Name string
+	rows map[string]map[string]string
+}
+
+// NewLookupTable matches the columns to the resource fields case insensitively, e.g. Application.
+// The columns that are not resource fields are dropped and logged.
+func NewLookupTable(name string, rows map[string]map[string]string) *LookupTable {
+	fieldNames := map[string]string{}
+	for field := range (&EvResource{}).stringFields() {
+		fieldNames[strings.ToLower(field)] = field
+	}
+
+	t := &LookupTable{Name: name, rows: map[string]map[string]string{}}
+	unknown := []string{}
+	for k, row := range rows {
+		fields := map[string]string{}
+		for column, v := range row {
+			field, ok := fieldNames[strings.ToLower(strings.TrimSpace(column))]
+			if !ok {
+				if !slices.Contains(unknown, column) {
+					unknown = append(unknown, column)
+				}
+				continue
+			}
+			fields[field] = v
+		}
+		t.rows[strings.ToLower(k)] = fields
+	}
+
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		log.Printf("Lookup table %s: ignored the columns that are not resource fields: %s", name, strings.Join(unknown, ", "))
+	}
+	return t
+}
+
+// LoadLookupTable reads a CSV file with a header line, or a JSON file holding either an object of rows by key
+// or an array of rows. The rows of the CSV and the JSON array are keyed by the keyColumn, e.g. hostname.
+// The keys must be unique regardless of their case.
+func LoadLookupTable(file, keyColumn string) (*LookupTable, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows map[string]map[string]string
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		rows, err = readLookupJSON(f, keyColumn)
+	} else {
+		rows, err = readLookupCSV(f, keyColumn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid lookup table %s: %w", file, err)
+	}
+	return NewLookupTable(filepath.Base(file), rows), nil
+}
+
+// lookupRows collects the rows of a file, a key must be unique regardless of its case as the lookup ignores it
+type lookupRows struct {
+	rows map[string]map[string]string
+	keys map[string]string //the key as first seen by its lower case
+}
+
+func newLookupRows() *lookupRows {
+	return &lookupRows{rows: map[string]map[string]string{}, keys: map[string]string{}}
+}
+
+func (l *lookupRows) add(key string, row map[string]string) error {
+	if first, ok := l.keys[strings.ToLower(key)]; ok {
+		return fmt.Errorf("duplicate key %q, already used by %q", key, first)
+	}
+	l.keys[strings.ToLower(key)] = key
+	l.rows[key] = row
+	return nil
+}
+
+func readLookupCSV(r io.Reader, keyColumn string) (map[string]map[string]string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header")
+	}
+	header := records[0]
+	keyIndex := slices.IndexFunc(header, func(column string) bool { return strings.EqualFold(strings.TrimSpace(column), keyColumn) })
+	if keyIndex < 0 {
+		return nil, fmt.Errorf("missing key column %s", keyColumn)
+	}
+
+	rows := newLookupRows()
+	for n, record := range records[1:] {
+		row := map[string]string{}
+		for i, v := range record {
+			if i != keyIndex && v != "" {
+				row[header[i]] = v
+			}
+		}
+		if err := rows.add(record[keyIndex], row); err != nil {
+			return nil, fmt.Errorf("row %d: %w", n+1, err)
+		}
+	}
+	return rows.rows, nil
+}
+
+func readLookupJSON(r io.Reader, keyColumn string) (map[string]map[string]string, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := newLookupRows()
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		//decoded key by key, as unmarshalling into a map keeps the last of the duplicate keys
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.Token()
+		for dec.More() {
+			token, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := token.(string)
+			var row map[string]string
+			if err := dec.Decode(&row); err != nil {
+				return nil, fmt.Errorf("row %s: expect an object of strings: %w", key, err)
+			}
+			if err := rows.add(key, row); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return nil, fmt.Errorf("unexpected data after the object of rows")
+		}
+		return rows.rows, nil
+	}
+
+	var list []map[string]string
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("expect an object of rows or an array of rows of strings: %w", err)
+	}
+	for i, row := range list {
+		found := false
+		for column, key := range row {
+			if strings.EqualFold(strings.TrimSpace(column), keyColumn) {
+				delete(row, column)
+				if err := rows.add(key, row); err != nil {
+					return nil, fmt.Errorf("row %d: %w", i, err)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("row %d has no %s", i, keyColumn)
+		}
+	}
+	return rows.rows, nil
+}
+
+func (t *LookupTable) LookupResource(key string) (map[string]string, bool) {
+	row, ok := t.rows[strings.ToLower(key)]
+	return row, ok
+}
+
+func (t *LookupTable) String() string {
+	return "table:" + t.Name
+}
+
+// TopologyIndex finds the vertex of a key among their MatchTokens, the resource fields are read from
+// the vertex properties of the same name, e.g. cluster or location, and the application from the tags app=<name>
+type TopologyIndex struct {
+	mu         sync.RWMutex
+	byToken    map[string]Vertex
+	byUniqueId map[string][]string //the tokens indexed for each vertex
+}
+
+func NewTopologyIndex(vertices ...Vertex) *TopologyIndex {
+	idx := &TopologyIndex{byToken: map[string]Vertex{}, byUniqueId: map[string][]string{}}
+	for _, v := range vertices {
+		idx.Add(v)
+	}
+	return idx
+}
+
+// Add indexes a vertex, e.g. as received by the topology observer. A vertex of the same UniqueId is replaced,
+// the tokens it no longer has are removed.
+func (idx *TopologyIndex) Add(v Vertex) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if v.UniqueId != "" {
+		idx.remove(v.UniqueId)
+	}
+
+	tokens := []string{}
+	for _, token := range v.MatchTokens {
+		token = strings.ToLower(token)
+		idx.byToken[token] = v
+		tokens = append(tokens, token)
+	}
+	if v.UniqueId != "" {
+		idx.byUniqueId[v.UniqueId] = tokens
+	}
+}
+
+// Remove forgets the vertex, e.g. deleted from the topology
+func (idx *TopologyIndex) Remove(uniqueId string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(uniqueId)
+}
+
+func (idx *TopologyIndex) remove(uniqueId string) {
+	for _, token := range idx.byUniqueId[uniqueId] {
+		//the token may have been taken over by another vertex since
+		if v, ok := idx.byToken[token]; ok && v.UniqueId == uniqueId {
+			delete(idx.byToken, token)
+		}
+	}
+	delete(idx.byUniqueId, uniqueId)
+}
+
+func (idx *TopologyIndex) LookupResource(key string) (map[string]string, bool) {
+	idx.mu.RLock()
+	v, ok := idx.byToken[strings.ToLower(key)]
+	idx.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	fields := map[string]string{}
+	for k, value := range v.Properties {
+		if s, ok := value.(string); ok && s != "" {
+			fields[k] = s
+		}
+	}
+	for _, tag := range v.Tags {
+		if app, ok := strings.CutPrefix(tag, "app="); ok && fields["application"] == "" {
+			fields["application"] = app
+		}
+	}
+	return fields, true
+}
+
+func (idx *TopologyIndex) String() string {
+	return "topology"
+}
+
+// ResourceEnricher fills the empty resource fields of the events from the lookups, tried in order.
+// The resource is looked up by its hostname, then its IP address, name and source id, a fully qualified
+// hostname by its short name too. The fields filled and the lookups used are recorded in Extras.
+type ResourceEnricher struct {
+	Lookups []ResourceLookup
+	Fields  []string //limits the fields filled, by JSON name, all the resource fields when empty
+}
+
+func NewResourceEnricher(lookups ...ResourceLookup) *ResourceEnricher {
+	return &ResourceEnricher{Lookups: lookups}
+}
+
+func (en *ResourceEnricher) Name() string {
+	return "enrich"
+}
+
+// Process implements the Processor of a pipeline, the events never fail nor are dropped
+func (en *ResourceEnricher) Process(ctx context.Context, e EvEvent) (EvEvent, bool, error) {
+	en.EnrichResource(&e.Resource)
+	return e, true, nil
+}
+
+// EnrichResource fills the resource in place, returning the names of the fields filled
+func (en *ResourceEnricher) EnrichResource(r *EvResource) []string {
+	keys := []string{}
+	for _, k := range []string{r.Hostname, r.IpAddress, r.Name, r.SourceId} {
+		if k == "" {
+			continue
+		}
+		keys = append(keys, k)
+		if short, _, ok := strings.Cut(k, "."); ok && net.ParseIP(k) == nil {
+			keys = append(keys, short)
+		}
+	}
+
+	fields := r.stringFields()
+	filled, by := []string{}, []string{}
+	for _, lookup := range en.Lookups {
+		for _, key := range keys {
+			row, ok := lookup.LookupResource(key)
+			if !ok {
+				continue
+			}
+			used := false
+			for name, value := range row {
+				field, ok := fields[name]
+				if !ok || *field != "" || (len(en.Fields) > 0 && !slices.Contains(en.Fields, name)) {
+					continue
+				}
+				*field = value
+				filled = append(filled, name)
+				used = true
+			}
+			if used {
+				by = append(by, lookupName(lookup))
+			}
+			break
+		}
+	}
+	if len(filled) == 0 {
+		return filled
+	}
+
+	slices.Sort(filled)
+	r.Extras = maps.Clone(r.Extras) //the map may be shared with the copies of the event
+	if r.Extras == nil {
+		r.Extras = map[string]any{}
+	}
+	r.Extras[EnrichedFieldsKey] = strings.Join(filled, ",")
+	r.Extras[EnrichedByKey] = strings.Join(by, ",")
+	return filled
+}
+
+func lookupName(lookup ResourceLookup) string {
+	if s, ok := lookup.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", lookup)
+}
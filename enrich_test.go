@@ -0,0 +1,136 @@
+package waiops
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLookupTable(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "hosts.csv")
+	os.WriteFile(csvFile, []byte("Hostname,Application, location,owner\nWEB01,shop,Paris,\ndb01,,London,dba\n"), 0600)
+	jsonFile := filepath.Join(dir, "hosts.json")
+	os.WriteFile(jsonFile, []byte(`[{"HostName": "web01", "Cluster": "ocp1"}]`), 0600)
+	objectFile := filepath.Join(dir, "apps.json")
+	os.WriteFile(objectFile, []byte(`{"WEB01": {"application": "shop"}, "db01": {"Service": "orders"}}`), 0600)
+
+	table, err := LoadLookupTable(csvFile, "hostname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row, ok := table.LookupResource("web01"); !ok || row["application"] != "shop" || row["location"] != "Paris" || len(row) != 2 {
+		t.Fatalf("expect the headers matched case insensitively and owner ignored, got %v", row)
+	}
+
+	table, err = LoadLookupTable(jsonFile, "hostname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row, ok := table.LookupResource("web01"); !ok || row["cluster"] != "ocp1" {
+		t.Fatalf("unexpected row %v", row)
+	}
+
+	table, err = LoadLookupTable(objectFile, "hostname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row, ok := table.LookupResource("web01"); !ok || row["application"] != "shop" {
+		t.Fatalf("unexpected row %v", row)
+	}
+	if row, ok := table.LookupResource("DB01"); !ok || row["service"] != "orders" {
+		t.Fatalf("unexpected row %v", row)
+	}
+
+	if _, err := LoadLookupTable(csvFile, "ipAddress"); err == nil {
+		t.Fatal("expect an error for the missing key column")
+	}
+}
+
+func TestLoadLookupTableErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, c := range map[string]struct{ content, want string }{
+		"dup.csv":       {"hostname,application\nweb01,shop\nWEB01,catalog\n", `row 2: duplicate key "WEB01", already used by "web01"`},
+		"dup-rows.json": {`[{"hostname": "web01"}, {"hostname": "web01"}]`, `row 1: duplicate key "web01"`},
+		"dup-keys.json": {`{"web01": {"application": "shop"}, "web01": {"application": "catalog"}}`, `duplicate key "web01"`},
+		"number.json":   {`{"web01": {"replicas": 3}}`, "row web01: expect an object of strings"},
+		"trailing.json": {`{"web01": {}} []`, "unexpected data"},
+	} {
+		file := filepath.Join(dir, name)
+		os.WriteFile(file, []byte(c.content), 0600)
+		if _, err := LoadLookupTable(file, "hostname"); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expect %q, got %v", name, c.want, err)
+		}
+	}
+}
+
+func TestResourceEnricher(t *testing.T) {
+	table := NewLookupTable("hosts", map[string]map[string]string{
+		"web01": {"application": "shop", "location": "Paris", "unknownField": "x"},
+	})
+	topology := NewTopologyIndex(*NewVertex("web01",
+		WithMatchTokens([]string{"web01", "10.0.0.1"}),
+		WithTags([]string{"app=catalog"}),
+	))
+	topology.Add(Vertex{MatchTokens: []string{"10.0.0.2"}, Properties: map[string]any{"cluster": "ocp1", "replicas": 3}})
+
+	enricher := NewResourceEnricher(table, topology)
+
+	e := NewRandomEvent()
+	e.Resource = EvResource{Hostname: "web01.example.com", IpAddress: "10.0.0.1", Location: "Lyon"}
+	e, keep, err := enricher.Process(context.Background(), e)
+	if !keep || err != nil {
+		t.Fatalf("expect the event to be kept, got %v %v", keep, err)
+	}
+
+	r := e.Resource
+	if r.Application != "shop" || r.Location != "Lyon" {
+		t.Fatalf("expect the application from the table and the location kept, got %+v", r)
+	}
+	if r.Extras[EnrichedFieldsKey] != "application" || r.Extras[EnrichedByKey] != "table:hosts" {
+		t.Fatalf("unexpected enrichment record %v", r.Extras)
+	}
+
+	r = EvResource{IpAddress: "10.0.0.2"}
+	if filled := enricher.EnrichResource(&r); len(filled) != 1 || r.Cluster != "ocp1" || r.Extras[EnrichedByKey] != "topology" {
+		t.Fatalf("expect the cluster from the topology, got %v %+v", filled, r)
+	}
+
+	r = EvResource{Hostname: "web01"}
+	if enricher.EnrichResource(&r); r.Extras[EnrichedFieldsKey] != "application,location" {
+		t.Fatalf("expect the unknown column ignored, got %v", r.Extras)
+	}
+
+	enricher.Fields = []string{"cluster"}
+	r = EvResource{Hostname: "web01"}
+	if filled := enricher.EnrichResource(&r); len(filled) != 0 || r.Extras != nil {
+		t.Fatalf("expect nothing filled outside the fields, got %v %+v", filled, r)
+	}
+}
+
+func TestTopologyIndexReplace(t *testing.T) {
+	topology := NewTopologyIndex(Vertex{UniqueId: "v1", MatchTokens: []string{"web01", "10.0.0.1"}, Properties: map[string]any{"cluster": "ocp1"}})
+	topology.Add(Vertex{UniqueId: "v2", MatchTokens: []string{"db01"}, Properties: map[string]any{"cluster": "ocp2"}})
+
+	//the address moved to another host
+	topology.Add(Vertex{UniqueId: "v1", MatchTokens: []string{"web01"}, Properties: map[string]any{"cluster": "ocp3"}})
+	if _, ok := topology.LookupResource("10.0.0.1"); ok {
+		t.Fatal("expect the stale token removed on replace")
+	}
+	if row, ok := topology.LookupResource("web01"); !ok || row["cluster"] != "ocp3" {
+		t.Fatalf("expect the new version, got %v", row)
+	}
+
+	//a token taken over by another vertex survives the removal of its previous owner
+	topology.Add(Vertex{UniqueId: "v2", MatchTokens: []string{"db01", "web01"}, Properties: map[string]any{"cluster": "ocp2"}})
+	topology.Remove("v1")
+	if row, ok := topology.LookupResource("web01"); !ok || row["cluster"] != "ocp2" {
+		t.Fatalf("expect web01 on v2, got %v", row)
+	}
+	topology.Remove("v2")
+	if _, ok := topology.LookupResource("db01"); ok {
+		t.Fatal("expect nothing left after the removal")
+	}
+}
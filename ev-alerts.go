@@ -2,9 +2,12 @@ package waiops
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -97,88 +100,102 @@ func (r EvResource) MarshalJSON() ([]byte, error) {
 	return json.Marshal(res)
 }
 
+func (r *EvResource) stringFields() map[string]*string {
+	return map[string]*string{
+		"name":         &r.Name,
+		"sourceId":     &r.SourceId,
+		"hostname":     &r.Hostname,
+		"ipAddress":    &r.IpAddress,
+		"service":      &r.Service,
+		"interface":    &r.Interface,
+		"application":  &r.Application,
+		"controller":   &r.Controller,
+		"component":    &r.Component,
+		"cluster":      &r.Cluster,
+		"location":     &r.Location,
+		"accessScope":  &r.AccessScope,
+		"connectionId": &r.ConnectionId,
+		"scopeId":      &r.ScopeId,
+	}
+}
+
+// UnmarshalJSON keeps a known field of an unexpected type in Extras under the same key, so nothing is lost
 func (r *EvResource) UnmarshalJSON(b []byte) error {
+	return r.decode(b, false)
+}
+
+// UnmarshalStrictJSON fails on known fields of an unexpected type instead of keeping them in Extras
+func (r *EvResource) UnmarshalStrictJSON(b []byte) error {
+	return r.decode(b, true)
+}
+
+func (r *EvResource) decode(b []byte, strict bool) error {
 	var dict map[string]any
 	if err := json.Unmarshal(b, &dict); err != nil {
 		return err
 	}
 
-	r.Extras = make(map[string]any)
+	*r = EvResource{Extras: make(map[string]any)}
+	fields := r.stringFields()
 
-	// Extract known fields
-	if name, ok := dict["name"].(string); ok {
-		r.Name = name
-	}
-	if sourceId, ok := dict["sourceId"].(string); ok {
-		r.SourceId = sourceId
-	}
-	if hostname, ok := dict["hostname"].(string); ok {
-		r.Hostname = hostname
-	}
-	if ipAddress, ok := dict["ipAddress"].(string); ok {
-		r.IpAddress = ipAddress
-	}
-	if service, ok := dict["service"].(string); ok {
-		r.Service = service
-	}
-	if port, ok := dict["port"].(int); ok {
-		r.Port = int(port)
-	}
-	if iface, ok := dict["interface"].(string); ok {
-		r.Interface = iface
-	}
-	if application, ok := dict["application"].(string); ok {
-		r.Application = application
-	}
-	if controller, ok := dict["controller"].(string); ok {
-		r.Controller = controller
-	}
-	if component, ok := dict["component"].(string); ok {
-		r.Component = component
-	}
-	if cluster, ok := dict["cluster"].(string); ok {
-		r.Cluster = cluster
-	}
-	if location, ok := dict["location"].(string); ok {
-		r.Location = location
-	}
-	if accessScope, ok := dict["accessScope"].(string); ok {
-		r.AccessScope = accessScope
-	}
-	if connectionId, ok := dict["connectionId"].(string); ok {
-		r.ConnectionId = connectionId
-	}
-	if scopeId, ok := dict["scopeId"].(string); ok {
-		r.ScopeId = scopeId
-	}
-
-	for _, key := range []string{
-		"name",
-		"sourceId",
-		"hostname",
-		"ipAddress",
-		"service",
-		"port",
-		"interface",
-		"application",
-		"controller",
-		"component",
-		"cluster",
-		"location",
-		"accessScope",
-		"connectionId",
-		"scopeId",
-	} {
-		delete(dict, key) // Remove it from the map even the above transform may fail
-	}
-	// Add remaining fields to the embedded map
+	mismatches := []error{}
 	for k, v := range dict {
+		ok := true
+		switch field, known := fields[k]; {
+		case known:
+			switch s := v.(type) {
+			case string:
+				*field = s
+			case nil: //null is taken as the zero value
+			default:
+				ok = false
+			}
+		case k == "port":
+			if v != nil {
+				r.Port, ok = parsePort(v)
+			}
+		default:
+			r.Extras[k] = v
+		}
+
+		if ok {
+			continue
+		}
+		if strict {
+			mismatches = append(mismatches, fmt.Errorf("%s: unexpected value %v (%T)", k, v, v))
+			continue
+		}
 		r.Extras[k] = v
 	}
 
+	if len(mismatches) > 0 {
+		return fmt.Errorf("invalid resource: %w", errors.Join(mismatches...))
+	}
 	return nil
 }
 
+// parsePort accepts a JSON number or a numeric string within the port range
+func parsePort(v any) (int, bool) {
+	var port float64
+	switch p := v.(type) {
+	case float64:
+		port = p
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return 0, false
+		}
+		port = float64(n)
+	default:
+		return 0, false
+	}
+
+	if port != math.Trunc(port) || port < 0 || port > 65535 {
+		return 0, false
+	}
+	return int(port), true
+}
+
 type EvType struct {
 	Classification string `json:"classification"`
 	EventType      string `json:"eventType"` // problem, resolution
@@ -192,18 +209,44 @@ func (t EvTime) MarshalJSON() ([]byte, error) {
 	return json.Marshal(time.Time(t).UTC().Format("2006-01-02T15:04:05.000Z"))
 }
 
+// UnmarshalJSON accepts the platform format and, for data exported from other tools, RFC3339 with or without
+// fraction and offset, epoch seconds or milliseconds as a number or a numeric string. null gives the zero time.
 func (t *EvTime) UnmarshalJSON(b []byte) error {
-	var s string
-	err := json.Unmarshal(b, &s)
-	if err != nil {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
 		return err
 	}
-	parsedTime, err := time.Parse("2006-01-02T15:04:05.000Z", s)
-	if err != nil {
-		return err
+
+	switch value := v.(type) {
+	case nil:
+		*t = EvTime(time.Time{})
+		return nil
+	case float64:
+		*t = EvTime(epochTime(value))
+		return nil
+	case string:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			*t = EvTime(epochTime(n))
+			return nil
+		}
+		for _, layout := range []string{"2006-01-02T15:04:05.000Z", time.RFC3339Nano, time.RFC3339} {
+			if parsedTime, err := time.Parse(layout, value); err == nil {
+				*t = EvTime(parsedTime)
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported time format: %s", value)
 	}
-	*t = EvTime(parsedTime)
-	return nil
+	return fmt.Errorf("unsupported time value: %s", string(b))
+}
+
+// epochTime takes values beyond 1e11 as milliseconds, seconds would only reach them in year 5138.
+// Fractions of seconds are kept to the millisecond, the precision of the platform format.
+func epochTime(n float64) time.Time {
+	if math.Abs(n) >= 1e11 {
+		return time.UnixMilli(int64(n)).UTC()
+	}
+	return time.UnixMilli(int64(math.Round(n * 1000))).UTC()
 }
 
 type EvInsight struct {
@@ -0,0 +1,137 @@
+package waiops
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+type TopicSummary struct {
+	Topic       string
+	Partitions  int
+	RetentionMs int64            //-1 for unlimited, 0 when not reported
+	Lag         map[string]int64 //total lag of each consumer group on the topic
+}
+
+// ListAIOpsTopics summarizes the topics prefixed with AIOpsTopicPrefix, including the lag of every consumer group reading them
+func ListAIOpsTopics(ctx context.Context, adm *kadm.Client) ([]TopicSummary, error) {
+	details, err := adm.ListTopics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := map[string]*TopicSummary{}
+	names := []string{}
+	for _, d := range details.Sorted() {
+		if !strings.HasPrefix(d.Topic, AIOpsTopicPrefix) {
+			continue
+		}
+		if d.Err != nil {
+			return nil, fmt.Errorf("failed to describe topic %s: %w", d.Topic, d.Err)
+		}
+		summaries[d.Topic] = &TopicSummary{
+			Topic:      d.Topic,
+			Partitions: len(d.Partitions),
+			Lag:        map[string]int64{},
+		}
+		names = append(names, d.Topic)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	configs, err := adm.DescribeTopicConfigs(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+	for _, rc := range configs {
+		s, ok := summaries[rc.Name]
+		if !ok || rc.Err != nil {
+			continue
+		}
+		for _, c := range rc.Configs {
+			if c.Key == "retention.ms" && c.Value != nil {
+				s.RetentionMs, _ = strconv.ParseInt(*c.Value, 10, 64)
+			}
+		}
+	}
+
+	groups, err := adm.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) > 0 {
+		lags, err := adm.Lag(ctx, groups.Groups()...)
+		if err != nil {
+			return nil, err
+		}
+		for _, gl := range lags {
+			if gl.Error() != nil {
+				continue
+			}
+			for topic, tl := range gl.Lag.TotalByTopic() {
+				if s, ok := summaries[topic]; ok {
+					s.Lag[gl.Group] = tl.Lag
+				}
+			}
+		}
+	}
+
+	result := []TopicSummary{}
+	for _, name := range names {
+		result = append(result, *summaries[name])
+	}
+	return result, nil
+}
+
+// CreateReplayTopic creates a short lived topic for replays or tests, kept for the given retention with the broker's default replication
+func CreateReplayTopic(ctx context.Context, adm *kadm.Client, topic string, partitions int32, retention time.Duration) error {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	if retention <= 0 {
+		retention = 24 * time.Hour
+	}
+
+	configs := map[string]*string{
+		"cleanup.policy": kadm.StringPtr("delete"),
+		"retention.ms":   kadm.StringPtr(strconv.FormatInt(retention.Milliseconds(), 10)),
+	}
+	_, err := adm.CreateTopic(ctx, partitions, -1, configs, topic)
+	return err
+}
+
+// ResetGroupOffsetsToTime moves the group's commits on the topics to the first record at or after t,
+// so the records are consumed again. The group must have no active members.
+func ResetGroupOffsetsToTime(ctx context.Context, adm *kadm.Client, group string, t time.Time, topics ...string) (kadm.Offsets, error) {
+	described, err := adm.DescribeGroups(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := described[group]; ok && !slices.Contains([]string{"Empty", "Dead", ""}, d.State) {
+		return nil, fmt.Errorf("group %s is %s, stop its consumers before resetting offsets", group, d.State)
+	}
+
+	listed, err := adm.ListOffsetsAfterMilli(ctx, t.UnixMilli(), topics...)
+	if err != nil {
+		return nil, err
+	}
+	if err := listed.Error(); err != nil {
+		return nil, err
+	}
+
+	offsets := listed.Offsets()
+	resp, err := adm.CommitOffsets(ctx, group, offsets)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
@@ -0,0 +1,226 @@
+package waiops
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// DefaultCaptureIdle is how long a capture waits for the next record when no idle is given
+const DefaultCaptureIdle = 10 * time.Second
+
+// CapturedRecord is one line of a capture file
+type CapturedRecord struct {
+	Topic     string           `json:"topic"`
+	Partition int32            `json:"partition"`
+	Offset    int64            `json:"offset"`
+	Timestamp time.Time        `json:"timestamp"`
+	Key       []byte           `json:"key,omitempty"`
+	Value     []byte           `json:"value"`
+	Headers   []CapturedHeader `json:"headers,omitempty"`
+}
+
+// CapturedHeader keeps the headers as a list, they can repeat and their order matters to some consumers
+type CapturedHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+func newCapturedRecord(r *kgo.Record) CapturedRecord {
+	c := CapturedRecord{
+		Topic:     r.Topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Timestamp: r.Timestamp,
+		Key:       r.Key,
+		Value:     r.Value,
+	}
+	for _, h := range r.Headers {
+		c.Headers = append(c.Headers, CapturedHeader{Key: h.Key, Value: h.Value})
+	}
+	return c
+}
+
+// Record rebuilds the kafka record for the target topic. The original partition is kept only when keepPartition is set,
+// kgo only honors it with a client built with kgo.RecordPartitioner(kgo.ManualPartitioner()).
+func (c CapturedRecord) Record(topic string, keepPartition bool) *kgo.Record {
+	r := &kgo.Record{
+		Topic:     topic,
+		Key:       c.Key,
+		Value:     c.Value,
+		Timestamp: c.Timestamp,
+	}
+	if keepPartition {
+		r.Partition = c.Partition
+	}
+	for _, h := range c.Headers {
+		r.Headers = append(r.Headers, kgo.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	return r
+}
+
+// CaptureRecords dumps the records of the topic with a timestamp within [from, to) into a gzip compressed JSON Lines file.
+// A zero to means no upper bound. The client must not be consuming already, records are read from its reset offset,
+// the start of the topic by default, up to the end the partitions had when the capture started. A partition is done
+// once it reaches that end or returns a record at or after to. The capture stops when every partition is done,
+// or when no record arrives for idle, DefaultCaptureIdle if not positive. The file is removed if the capture fails.
+func CaptureRecords(ctx context.Context, client *kgo.Client, topic string, from, to time.Time, idle time.Duration, file string) (int, error) {
+	if idle <= 0 {
+		idle = DefaultCaptureIdle
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+
+	gz := gzip.NewWriter(f)
+	count, err := captureRecords(ctx, client, topic, from, to, idle, gz)
+	if err = errors.Join(err, gz.Close(), f.Close()); err != nil {
+		os.Remove(file)
+		return count, err
+	}
+	log.Printf("Captured %d records from %s into %s", count, topic, file)
+	return count, nil
+}
+
+func captureRecords(ctx context.Context, client *kgo.Client, topic string, from, to time.Time, idle time.Duration, w io.Writer) (int, error) {
+	adm := kadm.NewClient(client)
+	starts, err := adm.ListStartOffsets(ctx, topic)
+	if err == nil {
+		err = starts.Error()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the start offsets of %s: %w", topic, err)
+	}
+	ends, err := adm.ListEndOffsets(ctx, topic)
+	if err == nil {
+		err = ends.Error()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the end offsets of %s: %w", topic, err)
+	}
+
+	//the end offset of each partition still to be read
+	pending := map[int32]int64{}
+	ends.Each(func(o kadm.ListedOffset) {
+		if start, ok := starts.Lookup(topic, o.Partition); !ok || o.Offset > start.Offset {
+			pending[o.Partition] = o.Offset
+		}
+	})
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	client.AddConsumeTopics(topic)
+	defer client.PurgeTopicsFromConsuming(topic)
+
+	enc := json.NewEncoder(w)
+	count := 0
+	for len(pending) > 0 {
+		pollCtx, cancel := context.WithTimeout(ctx, idle)
+		fetches := client.PollFetches(pollCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+		idled := false
+		for _, e := range fetches.Errors() {
+			if errors.Is(e.Err, context.DeadlineExceeded) {
+				idled = true
+				continue
+			}
+			return count, fmt.Errorf("failed to fetch %s[%d]: %w", e.Topic, e.Partition, e.Err)
+		}
+
+		fetches.EachRecord(func(r *kgo.Record) {
+			end, ok := pending[r.Partition]
+			if err != nil || !ok || r.Offset >= end {
+				return
+			}
+			if r.Offset+1 >= end || (!to.IsZero() && !r.Timestamp.Before(to)) {
+				delete(pending, r.Partition)
+			}
+			if r.Timestamp.Before(from) || (!to.IsZero() && !r.Timestamp.Before(to)) {
+				return
+			}
+			if err = enc.Encode(newCapturedRecord(r)); err == nil {
+				count++
+			}
+		})
+		if err != nil {
+			return count, err
+		}
+		if idled {
+			break
+		}
+	}
+	return count, nil
+}
+
+// ReadCapture calls fn for each record of a capture file, in the captured order
+func ReadCapture(file string, fn func(CapturedRecord) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	for line := 1; ; line++ {
+		var c CapturedRecord
+		if err := dec.Decode(&c); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("record %d of %s: %w", line, file, err)
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+}
+
+// ReplayCapture publishes every record of a capture file to the topic. To keep the captured partitions,
+// the client must be built with kgo.RecordPartitioner(kgo.ManualPartitioner()) and the topic must have them.
+func ReplayCapture(ctx context.Context, client *kgo.Client, file, topic string, keepPartition bool) (int, error) {
+	if keepPartition && !usesManualPartitioner(client) {
+		return 0, fmt.Errorf("keeping the partitions requires a client built with kgo.RecordPartitioner(kgo.ManualPartitioner())")
+	}
+
+	count := 0
+	err := ReadCapture(file, func(c CapturedRecord) error {
+		if err := client.ProduceSync(ctx, c.Record(topic, keepPartition)).FirstErr(); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	log.Printf("Replayed %d records from %s into %s", count, file, topic)
+	return count, nil
+}
+
+func usesManualPartitioner(client *kgo.Client) bool {
+	return reflect.TypeOf(client.OptValue(kgo.RecordPartitioner)) == reflect.TypeOf(kgo.ManualPartitioner())
+}
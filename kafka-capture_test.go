@@ -0,0 +1,220 @@
+package waiops_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	. "github.com/zhiminwen/waiops"
+	"github.com/zhiminwen/waiops/kafkatest"
+)
+
+func TestReplayTopicAndGroupReset(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	ctx := context.Background()
+
+	client, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	adm := AdminClient(client)
+
+	topic := AIOpsTopicPrefix + "test.replay"
+	if err := CreateReplayTopic(ctx, adm, topic, 2, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := client.ProduceSync(ctx, &kgo.Record{Topic: topic, Partition: int32(i % 2), Value: []byte("x")}).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := ResetGroupOffsetsToTime(ctx, adm, "replayer", start.Add(-time.Minute), topic); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries, err := ListAIOpsTopics(ctx, adm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(summaries, func(s TopicSummary) bool { return s.Topic == topic })
+	if i < 0 {
+		t.Fatalf("expected %s in %+v", topic, summaries)
+	}
+	if summaries[i].Partitions != 2 || summaries[i].RetentionMs != time.Hour.Milliseconds() {
+		t.Fatalf("unexpected summary %+v", summaries[i])
+	}
+	if summaries[i].Lag["replayer"] != 4 {
+		t.Fatalf("expected a lag of 4 after the reset, got %v", summaries[i].Lag)
+	}
+}
+
+func TestCaptureAndReplay(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	ctx := context.Background()
+
+	client, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		record := &kgo.Record{
+			Topic:   TopicReplayAlerts,
+			Key:     []byte{byte(i)},
+			Value:   []byte("alert"),
+			Headers: []kgo.RecordHeader{{Key: "source", Value: []byte("test")}},
+		}
+		if err := client.ProduceSync(ctx, record).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "alerts.jsonl.gz")
+	count, err := CaptureRecords(ctx, client, TopicReplayAlerts, time.Now().Add(-time.Hour), time.Time{}, time.Second, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 captured records, got %d", count)
+	}
+
+	if _, err := ReplayCapture(ctx, client, file, TopicRequestsAlerts, false); err != nil {
+		t.Fatal(err)
+	}
+	records := kafka.ExpectRecords(t, TopicRequestsAlerts, 3)
+	if string(records[2].Key) != "\x02" || len(records[2].Headers) != 1 || string(records[2].Headers[0].Value) != "test" {
+		t.Fatalf("expected key and headers to be preserved, got %+v", records[2])
+	}
+}
+
+func TestCaptureStopsOnBusyTopic(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	client, err := kafka.Client("admin", kgo.DefaultProduceTopic(TopicReplayAlerts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	to := time.Now().Add(-time.Minute)
+	records := []*kgo.Record{
+		{Value: []byte("old"), Timestamp: to.Add(-time.Minute)},
+		{Value: []byte("old"), Timestamp: to.Add(-time.Minute)},
+		{Value: []byte("new")},
+	}
+	if err := client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	//keeps producing while capturing, an idle timeout would never be reached
+	producerCtx, stopProducer := context.WithCancel(ctx)
+	defer stopProducer()
+	go func() {
+		for producerCtx.Err() == nil {
+			client.ProduceSync(producerCtx, &kgo.Record{Value: []byte("new")})
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	capture, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Close()
+
+	dir := t.TempDir()
+	count, err := CaptureRecords(ctx, capture, TopicReplayAlerts, time.Time{}, to, time.Hour, filepath.Join(dir, "bounded.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expected the 2 records before to, got %d", count)
+	}
+
+	//without to, the capture ends at the end the topic had when it started
+	count, err = CaptureRecords(ctx, capture, TopicReplayAlerts, time.Time{}, time.Time{}, time.Hour, filepath.Join(dir, "all.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count < 3 {
+		t.Fatalf("expected the new records too, got %d", count)
+	}
+	read := 0
+	if err := ReadCapture(filepath.Join(dir, "all.jsonl.gz"), func(CapturedRecord) error { read++; return nil }); err != nil || read != count {
+		t.Fatalf("expected a readable capture of %d records, got %d: %v", count, read, err)
+	}
+
+	canceled, cancelCapture := context.WithCancel(ctx)
+	cancelCapture()
+	failed := filepath.Join(dir, "failed.jsonl.gz")
+	if _, err := CaptureRecords(canceled, capture, TopicReplayAlerts, time.Time{}, time.Time{}, time.Hour, failed); err == nil {
+		t.Fatal("expected an error for a canceled capture")
+	}
+	if _, err := os.Stat(failed); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial file to be removed, got %v", err)
+	}
+}
+
+func TestReplayKeepsPartitions(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	ctx := context.Background()
+
+	client, err := kafka.Client("admin", kgo.RecordPartitioner(kgo.ManualPartitioner()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	adm := AdminClient(client)
+
+	source, target := AIOpsTopicPrefix+"test.source", AIOpsTopicPrefix+"test.target"
+	for _, topic := range []string{source, target} {
+		if err := CreateReplayTopic(ctx, adm, topic, 2, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := []*kgo.Record{
+		{Topic: source, Partition: 1, Value: []byte("a")},
+		{Topic: source, Partition: 1, Value: []byte("b")},
+		{Topic: source, Partition: 0, Value: []byte("c")},
+	}
+	if err := client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	capture, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Close()
+	file := filepath.Join(t.TempDir(), "source.jsonl.gz")
+	//no idle given, the default applies instead of stopping after the first poll
+	if count, err := CaptureRecords(ctx, capture, source, time.Time{}, time.Time{}, 0, file); err != nil || count != 3 {
+		t.Fatalf("expected 3 captured records, got %d: %v", count, err)
+	}
+
+	if _, err := ReplayCapture(ctx, capture, file, target, true); err == nil {
+		t.Fatal("expected an error keeping the partitions without a manual partitioner")
+	}
+	if _, err := ReplayCapture(ctx, client, file, target, true); err != nil {
+		t.Fatal(err)
+	}
+	partitions := map[string]int32{}
+	for _, r := range kafka.ExpectRecords(t, target, 3) {
+		partitions[string(r.Value)] = r.Partition
+	}
+	if partitions["a"] != 1 || partitions["b"] != 1 || partitions["c"] != 0 {
+		t.Fatalf("expected the captured partitions, got %v", partitions)
+	}
+}
@@ -0,0 +1,210 @@
+// Package kafkatest provides an in process kafka cluster seeded with the AIOps topics,
+// so code producing to or consuming from AIOps can be tested offline.
+package kafkatest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/zhiminwen/waiops"
+)
+
+// FakeKafka is an in process kafka cluster listening with TLS and SASL/SCRAM-SHA-512,
+// seeded with the AIOps topics, so code using NewSASL512Client can be tested offline.
+type FakeKafka struct {
+	Cluster *kfake.Cluster
+	CAFile  string //PEM of the self signed CA, to be passed to NewSASL512Client
+	Users   map[string]string
+	Grace   time.Duration //how long ExpectRecords waits for records beyond the expected ones, 500ms by default
+
+	dir string
+}
+
+// NewFakeKafka starts the cluster. Users are the SCRAM-SHA-512 user/password pairs, admin/admin is used if empty.
+// Extra kfake options, e.g. more seeded topics, are applied after the defaults.
+func NewFakeKafka(users map[string]string, opts ...kfake.Opt) (*FakeKafka, error) {
+	if len(users) == 0 {
+		users = map[string]string{"admin": "admin"}
+	}
+
+	dir, err := os.MkdirTemp("", "waiops-kfake")
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, caFile, err := selfSignedTLS(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	myOpts := []kfake.Opt{
+		kfake.NumBrokers(1),
+		kfake.TLS(tlsConfig),
+		kfake.EnableSASL(),
+		kfake.SeedTopics(1, waiops.AIOpsTopics...),
+	}
+	for user, pass := range users {
+		myOpts = append(myOpts, kfake.Superuser("SCRAM-SHA-512", user, pass))
+	}
+	myOpts = append(myOpts, opts...)
+
+	cluster, err := kfake.NewCluster(myOpts...)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &FakeKafka{
+		Cluster: cluster,
+		CAFile:  caFile,
+		Users:   users,
+		Grace:   500 * time.Millisecond,
+		dir:     dir,
+	}, nil
+}
+
+// MustFakeKafka starts the cluster and closes it when the test finishes
+func MustFakeKafka(t testing.TB, users map[string]string, opts ...kfake.Opt) *FakeKafka {
+	t.Helper()
+	f, err := NewFakeKafka(users, opts...)
+	if err != nil {
+		t.Fatalf("failed to start fake kafka: %v", err)
+	}
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *FakeKafka) Close() {
+	f.Cluster.Close()
+	os.RemoveAll(f.dir)
+}
+
+func (f *FakeKafka) Brokers() []string {
+	return f.Cluster.ListenAddrs()
+}
+
+// Client creates a client through NewSASL512Client, the same way production code does
+func (f *FakeKafka) Client(user string, opts ...kgo.Opt) (*kgo.Client, error) {
+	pass, ok := f.Users[user]
+	if !ok {
+		return nil, fmt.Errorf("unknown user: %s", user)
+	}
+	myOpts := append([]kgo.Opt{kgo.SeedBrokers(f.Brokers()...)}, opts...)
+	return waiops.NewSASL512Client(f.CAFile, user, pass, myOpts...)
+}
+
+// Records reads every record currently on the topic, waiting at most timeout for at least min records,
+// then for grace more to catch the records beyond min
+func (f *FakeKafka) Records(topic string, min int, timeout time.Duration, grace time.Duration) ([]*kgo.Record, error) {
+	var user string
+	for user = range f.Users {
+		break
+	}
+	client, err := f.Client(user,
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	records := []*kgo.Record{}
+	for len(records) < min {
+		fetches := client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return records, fmt.Errorf("got %d of %d records from %s: %w", len(records), min, topic, ctx.Err())
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return records, errs[0].Err
+		}
+		records = append(records, fetches.Records()...)
+	}
+
+	graceCtx, cancelGrace := context.WithTimeout(context.Background(), grace)
+	defer cancelGrace()
+	for graceCtx.Err() == nil {
+		fetches := client.PollFetches(graceCtx)
+		if graceCtx.Err() != nil {
+			break
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return records, errs[0].Err
+		}
+		records = append(records, fetches.Records()...)
+	}
+	return records, nil
+}
+
+// ExpectRecords fails the test unless exactly n records are on the topic, including the ones arriving within Grace
+func (f *FakeKafka) ExpectRecords(t testing.TB, topic string, n int) []*kgo.Record {
+	t.Helper()
+	records, err := f.Records(topic, n, 10*time.Second, f.Grace)
+	if err != nil {
+		t.Fatalf("failed to read records: %v", err)
+	}
+	if len(records) != n {
+		t.Fatalf("expected %d records on %s, got %d", n, topic, len(records))
+	}
+	return records
+}
+
+func selfSignedTLS(dir string) (*tls.Config, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "waiops-kfake"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, "", err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		return nil, "", err
+	}
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, certPem, 0o600); err != nil {
+		return nil, "", err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, caFile, nil
+}
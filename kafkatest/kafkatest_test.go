@@ -0,0 +1,73 @@
+package kafkatest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/zhiminwen/waiops"
+)
+
+func TestFakeKafkaProduceAndAssert(t *testing.T) {
+	kafka := MustFakeKafka(t, map[string]string{"aiops": "secret"})
+
+	client, err := kafka.Client("aiops", kgo.DefaultProduceTopic(waiops.TopicReplayAlerts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	alert := waiops.NewRandomAlert()
+	if err := client.ProduceSync(context.Background(), &kgo.Record{Value: alert.AsJson()}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := kafka.ExpectRecords(t, waiops.TopicReplayAlerts, 1)
+	var got waiops.EvAlert
+	if err := json.Unmarshal(records[0].Value, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != alert.Id {
+		t.Fatalf("expected alert %s, got %s", alert.Id, got.Id)
+	}
+}
+
+func TestFakeKafkaRejectsWrongPassword(t *testing.T) {
+	kafka := MustFakeKafka(t, map[string]string{"aiops": "secret"})
+
+	client, err := waiops.NewSASL512Client(kafka.CAFile, "aiops", "wrong", kgo.SeedBrokers(kafka.Brokers()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Ping(context.Background()); err == nil {
+		t.Fatal("expected authentication to fail")
+	}
+}
+
+func TestRecordsBeyondExpected(t *testing.T) {
+	kafka := MustFakeKafka(t, nil)
+
+	client, err := kafka.Client("admin", kgo.DefaultProduceTopic(waiops.TopicRequestsAlerts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	records := []*kgo.Record{{Value: []byte("1")}, {Value: []byte("2")}, {Value: []byte("3")}}
+	if err := client.ProduceSync(context.Background(), records...).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	//ExpectRecords(t, topic, 2) must see the 3rd record to fail
+	got, err := kafka.Records(waiops.TopicRequestsAlerts, 2, 10*time.Second, kafka.Grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected the 3 records, got %d", len(got))
+	}
+}
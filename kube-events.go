@@ -0,0 +1,256 @@
+package waiops
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultKubeRecoveries maps the reasons of the Normal events telling a problem is over to the reason of that problem,
+// so the resolution carries the condition of the problem it clears. A recovery is only sent for a problem
+// seen on the same object, e.g. the Started of a container resolves its BackOff, not every start.
+var DefaultKubeRecoveries = map[string]string{
+	"NodeReady":               "NodeNotReady",
+	"NodeSchedulable":         "NodeNotSchedulable",
+	"NodeHasSufficientMemory": "NodeHasInsufficientMemory",
+	"NodeHasNoDiskPressure":   "NodeHasDiskPressure",
+	"NodeHasSufficientPID":    "NodeHasInsufficientPID",
+	"Started":                 "BackOff",
+	"SuccessfulCreate":        "FailedCreate",
+	"SuccessfulMountVolume":   "FailedMount",
+	"Scheduled":               "FailedScheduling",
+}
+
+// KubeEventSource turns the Kubernetes Events into events: the Warning ones as problems,
+// the Normal ones of the Recoveries as resolutions of a problem seen, the other Normal ones are skipped
+type KubeEventSource struct {
+	Client      kubernetes.Interface
+	Namespace   string //all the namespaces when empty
+	ClusterName string
+	Recoveries  map[string]string
+	Sink        EventSink
+
+	mu    sync.Mutex
+	open  map[string]time.Time //last time of the problems not resolved yet, by object and reason
+	swept time.Time
+}
+
+// kubeProblemTTL forgets the problems never resolved, e.g. of deleted pods, well beyond the default retention of the Events
+const kubeProblemTTL = 24 * time.Hour
+
+// track records the problem of the object, or consumes it for a recovery, telling whether there was one to resolve
+func (s *KubeEventSource) track(obj corev1.ObjectReference, reason string, recovery bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.open == nil {
+		s.open, s.swept = map[string]time.Time{}, now
+	}
+	if now.Sub(s.swept) > time.Hour {
+		for k, last := range s.open {
+			if now.Sub(last) > kubeProblemTTL {
+				delete(s.open, k)
+			}
+		}
+		s.swept = now
+	}
+
+	key := strings.Join([]string{obj.Kind, obj.Namespace, obj.Name, obj.FieldPath, reason}, "/")
+	if !recovery {
+		s.open[key] = now
+		return true
+	}
+	_, ok := s.open[key]
+	delete(s.open, key)
+	return ok
+}
+
+func NewKubeEventSource(client kubernetes.Interface, sink EventSink) *KubeEventSource {
+	return &KubeEventSource{
+		Client:     client,
+		Sink:       sink,
+		Recoveries: DefaultKubeRecoveries,
+	}
+}
+
+// Convert returns the event of the Kubernetes Event, false when it is not relevant.
+// The Events must be converted in their order, a recovery is only relevant after the problem it resolves.
+func (s *KubeEventSource) Convert(ke *corev1.Event) (EvEvent, bool) {
+	eventType, severity, condition := "problem", 4, ke.Reason
+	if ke.Type != corev1.EventTypeWarning {
+		problem, ok := s.Recoveries[ke.Reason]
+		if !ok || !s.track(ke.InvolvedObject, problem, true) {
+			return EvEvent{}, false
+		}
+		eventType, severity, condition = "resolution", 2, problem
+	} else {
+		s.track(ke.InvolvedObject, ke.Reason, false)
+	}
+
+	obj := ke.InvolvedObject
+	resource := EvResource{
+		Name:     obj.Name,
+		Cluster:  s.ClusterName,
+		Hostname: ke.Source.Host,
+		Extras: map[string]any{
+			"kind": obj.Kind,
+		},
+	}
+	if obj.Namespace != "" {
+		resource.Extras["namespace"] = obj.Namespace
+	}
+	if obj.Kind == "Pod" {
+		resource.Extras["pod"] = obj.Name
+	}
+	if obj.Kind == "Node" {
+		resource.Hostname = obj.Name
+	}
+	if container := fieldPathContainer(obj.FieldPath); container != "" {
+		resource.Component = container
+		resource.Extras["container"] = container
+	}
+
+	event := EvEvent{
+		Id:             fmt.Sprintf("%s-%d", ke.UID, ke.Count),
+		OccurrenceTime: EvTime(kubeEventTime(ke)),
+		Summary:        strings.TrimSpace(ke.Message),
+		Severity:       severity,
+		Sender:         EvResource{Name: "kubernetes", Service: ke.Source.Component},
+		Resource:       resource,
+		Type: EvType{
+			Classification: "Kubernetes " + obj.Kind,
+			EventType:      eventType,
+			Condition:      condition,
+		},
+		Details: map[string]string{
+			"reason": ke.Reason,
+			"count":  strconv.Itoa(int(ke.Count)),
+			"uid":    string(obj.UID),
+		},
+	}
+	if event.Summary == "" {
+		event.Summary = fmt.Sprintf("%s %s %s", ke.Reason, strings.ToLower(obj.Kind), obj.Name)
+	}
+	if event.Sender.Service == "" {
+		event.Sender.Service = ke.ReportingController
+	}
+	if obj.FieldPath != "" {
+		event.Details["fieldPath"] = obj.FieldPath
+	}
+	return event, true
+}
+
+// kubeEventTime is the last time the Event occurred, falling back on the fields the reporters set instead
+func kubeEventTime(ke *corev1.Event) time.Time {
+	occurrence := ke.LastTimestamp.Time
+	for _, t := range []time.Time{ke.EventTime.Time, ke.FirstTimestamp.Time, ke.CreationTimestamp.Time} {
+		if occurrence.IsZero() {
+			occurrence = t
+		}
+	}
+	return occurrence
+}
+
+// fieldPathContainer extracts the container name of the field path, e.g. spec.containers{app}
+func fieldPathContainer(fieldPath string) string {
+	for _, prefix := range []string{"spec.containers{", "spec.initContainers{", "spec.ephemeralContainers{"} {
+		if name, ok := strings.CutPrefix(fieldPath, prefix); ok {
+			return strings.TrimSuffix(name, "}")
+		}
+	}
+	return ""
+}
+
+// List converts the Events currently held by the API server in their time order, returning the resource version
+// of the list to watch from
+func (s *KubeEventSource) List(ctx context.Context) ([]EvEvent, string, error) {
+	list, err := s.Client.CoreV1().Events(s.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	slices.SortStableFunc(list.Items, func(a, b corev1.Event) int {
+		return kubeEventTime(&a).Compare(kubeEventTime(&b))
+	})
+	events := []EvEvent{}
+	for i := range list.Items {
+		if e, ok := s.Convert(&list.Items[i]); ok {
+			events = append(events, e)
+		}
+	}
+	return events, list.ResourceVersion, nil
+}
+
+// Run watches the Events created or updated after its start and sends them to the Sink, until the context is done.
+// The watch is resumed when the API server closes it, and restarted from a fresh list when its version expired.
+func (s *KubeEventSource) Run(ctx context.Context) error {
+	_, version, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		w, err := s.Client.CoreV1().Events(s.Namespace).Watch(ctx, metav1.ListOptions{ResourceVersion: version})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		version, err = s.consume(ctx, w, version)
+		w.Stop()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			if _, version, err = s.List(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *KubeEventSource) consume(ctx context.Context, w watch.Interface, version string) (string, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return version, nil
+		case item, ok := <-w.ResultChan():
+			if !ok {
+				return version, nil
+			}
+			switch item.Type {
+			case watch.Error:
+				return version, apierrors.FromObject(item.Object)
+			case watch.Added, watch.Modified:
+				ke, ok := item.Object.(*corev1.Event)
+				if !ok {
+					continue
+				}
+				version = ke.ResourceVersion
+				e, ok := s.Convert(ke)
+				if !ok {
+					continue
+				}
+				if err := s.Sink.SendEvent(ctx, e); err != nil {
+					log.Printf("Failed to send the event of %s/%s: %v", ke.Namespace, ke.Name, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package waiops
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func kubeEvent(name, eventType, reason, kind, object string) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", UID: types.UID("uid-" + name)},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      kind,
+			Namespace: "shop",
+			Name:      object,
+			FieldPath: "spec.containers{app}",
+		},
+		Type:          eventType,
+		Reason:        reason,
+		Message:       reason + " of " + object,
+		Count:         2,
+		Source:        corev1.EventSource{Component: "kubelet", Host: "worker1"},
+		LastTimestamp: metav1.NewTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)),
+	}
+}
+
+func TestKubeEventSourceList(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		kubeEvent("a", corev1.EventTypeWarning, "BackOff", "Pod", "web-1"),
+		kubeEvent("b", corev1.EventTypeNormal, "Started", "Pod", "web-1"),
+		kubeEvent("c", corev1.EventTypeNormal, "Killing", "Pod", "web-1"),
+	)
+	source := NewKubeEventSource(client, nil)
+	source.ClusterName = "ocp1"
+
+	events, _, err := source.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expect the Killing event to be skipped, got %d events", len(events))
+	}
+
+	problem, resolution := events[0], events[1]
+	if problem.Type.EventType != "problem" || problem.Type.Condition != "BackOff" || problem.Type.Classification != "Kubernetes Pod" {
+		t.Fatalf("unexpected problem type %+v", problem.Type)
+	}
+	r := problem.Resource
+	if r.Name != "web-1" || r.Cluster != "ocp1" || r.Hostname != "worker1" || r.Component != "app" ||
+		r.Extras["namespace"] != "shop" || r.Extras["pod"] != "web-1" || r.Extras["container"] != "app" {
+		t.Fatalf("unexpected resource %+v", r)
+	}
+	if err := problem.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if resolution.Type.EventType != "resolution" || resolution.Type.Condition != "BackOff" {
+		t.Fatalf("expect the resolution of BackOff, got %+v", resolution.Type)
+	}
+}
+
+func TestKubeEventSourceRecoveries(t *testing.T) {
+	source := NewKubeEventSource(fake.NewSimpleClientset(), nil)
+	convert := func(ke *corev1.Event) string {
+		e, ok := source.Convert(ke)
+		if !ok {
+			return "skipped"
+		}
+		return e.Type.EventType
+	}
+
+	if got := convert(kubeEvent("a", corev1.EventTypeNormal, "Started", "Pod", "web-1")); got != "skipped" {
+		t.Fatalf("expect a first start not to be a resolution, got %s", got)
+	}
+	if got := convert(kubeEvent("b", corev1.EventTypeWarning, "BackOff", "Pod", "web-1")); got != "problem" {
+		t.Fatalf("expect the BackOff as a problem, got %s", got)
+	}
+
+	other := kubeEvent("c", corev1.EventTypeNormal, "Started", "Pod", "web-1")
+	other.InvolvedObject.FieldPath = "spec.containers{sidecar}"
+	if got := convert(other); got != "skipped" {
+		t.Fatalf("expect the start of another container not to resolve the BackOff, got %s", got)
+	}
+	if got := convert(kubeEvent("d", corev1.EventTypeNormal, "Started", "Pod", "web-2")); got != "skipped" {
+		t.Fatalf("expect the start of another pod not to resolve the BackOff, got %s", got)
+	}
+
+	if got := convert(kubeEvent("e", corev1.EventTypeNormal, "Started", "Pod", "web-1")); got != "resolution" {
+		t.Fatalf("expect the start to resolve the BackOff, got %s", got)
+	}
+	if got := convert(kubeEvent("e", corev1.EventTypeNormal, "Started", "Pod", "web-1")); got != "skipped" {
+		t.Fatalf("expect the BackOff to be resolved once, got %s", got)
+	}
+}
+
+func TestKubeEventSourceRun(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	watcher := watch.NewFake()
+	client.PrependWatchReactor("events", k8stesting.DefaultWatchReactor(watcher, nil))
+
+	events := make(chan EvEvent, 1)
+	source := NewKubeEventSource(client, EventSinkFunc(func(ctx context.Context, e EvEvent) error {
+		events <- e
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- source.Run(ctx) }()
+
+	watcher.Add(kubeEvent("a", corev1.EventTypeWarning, "NodeNotReady", "Node", "worker2"))
+	select {
+	case e := <-events:
+		if e.Type.Condition != "NodeNotReady" || e.Resource.Hostname != "worker2" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,181 @@
+package waiops
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KubeTopologySource builds the vertices of the Nodes, Deployments, Pods and Services:
+// a deployment contains its pods, a pod runsOn its node, a service exposes the pods it selects
+type KubeTopologySource struct {
+	Client      kubernetes.Interface
+	Namespace   string //all the namespaces when empty, the nodes are always listed
+	ClusterName string //prefixes the unique ids, so several clusters can share a provider
+	Provider    string
+}
+
+func NewKubeTopologySource(client kubernetes.Interface, clusterName string) *KubeTopologySource {
+	return &KubeTopologySource{
+		Client:      client,
+		ClusterName: clusterName,
+		Provider:    "kubernetes",
+	}
+}
+
+// UniqueId of an object, namespace empty for the nodes
+func (s *KubeTopologySource) UniqueId(kind, namespace, name string) string {
+	parts := []string{strings.ToLower(kind)}
+	if s.ClusterName != "" {
+		parts = append([]string{s.ClusterName}, parts...)
+	}
+	if namespace != "" {
+		parts = append(parts, namespace)
+	}
+	return strings.Join(append(parts, name), "/")
+}
+
+func (s *KubeTopologySource) vertex(kind string, meta metav1.ObjectMeta, tokens ...string) *Vertex {
+	matchTokens := []string{}
+	for _, t := range append([]string{meta.Name}, tokens...) {
+		if t != "" && !slices.Contains(matchTokens, t) {
+			matchTokens = append(matchTokens, t)
+		}
+	}
+	v := NewVertex(meta.Name,
+		WithUniqueId(s.UniqueId(kind, meta.Namespace, meta.Name)),
+		WithEntityTypes([]string{strings.ToLower(kind)}),
+		WithMatchTokens(matchTokens),
+		WithProvider(s.Provider),
+	)
+	v.Properties["uid"] = string(meta.UID)
+	if meta.Namespace != "" {
+		v.Properties["namespace"] = meta.Namespace
+	}
+	if s.ClusterName != "" {
+		v.Properties["cluster"] = s.ClusterName
+	}
+	for k, value := range meta.Labels {
+		v.Tags = append(v.Tags, k+"="+value)
+	}
+	slices.Sort(v.Tags)
+	return v
+}
+
+// Vertices lists the objects and returns their vertices, the references held by the vertex they start from
+func (s *KubeTopologySource) Vertices(ctx context.Context) ([]Vertex, error) {
+	opts := metav1.ListOptions{}
+	nodes, err := s.Client.CoreV1().Nodes().List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := s.Client.CoreV1().Pods(s.Namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	replicaSets, err := s.Client.AppsV1().ReplicaSets(s.Namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := s.Client.AppsV1().Deployments(s.Namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	services, err := s.Client.CoreV1().Services(s.Namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	vertices := []Vertex{}
+	for _, n := range nodes.Items {
+		tokens := []string{}
+		for _, addr := range n.Status.Addresses {
+			tokens = append(tokens, addr.Address)
+		}
+		v := s.vertex("Node", n.ObjectMeta, tokens...)
+		v.Properties["kubeletVersion"] = n.Status.NodeInfo.KubeletVersion
+		v.Properties["ready"] = nodeReady(&n)
+		vertices = append(vertices, *v)
+	}
+
+	//the deployment owning each replica set, by namespace/name
+	rsDeployment := map[string]string{}
+	for _, rs := range replicaSets.Items {
+		if owner := controllerOf(rs.OwnerReferences, "Deployment"); owner != "" {
+			rsDeployment[rs.Namespace+"/"+rs.Name] = owner
+		}
+	}
+	deploymentPods := map[string][]string{}
+
+	podVertices := []Vertex{}
+	for _, p := range pods.Items {
+		v := s.vertex("Pod", p.ObjectMeta, p.Status.PodIP)
+		v.Properties["phase"] = string(p.Status.Phase)
+		if p.Spec.NodeName != "" {
+			v.Properties["node"] = p.Spec.NodeName
+			WithToReferences(s.UniqueId("Node", "", p.Spec.NodeName), "runsOn")(v)
+		}
+		if rs := controllerOf(p.OwnerReferences, "ReplicaSet"); rs != "" {
+			if d, ok := rsDeployment[p.Namespace+"/"+rs]; ok {
+				key := p.Namespace + "/" + d
+				deploymentPods[key] = append(deploymentPods[key], v.UniqueId)
+			}
+		}
+		podVertices = append(podVertices, *v)
+	}
+
+	for _, d := range deployments.Items {
+		v := s.vertex("Deployment", d.ObjectMeta)
+		if d.Spec.Replicas != nil {
+			v.Properties["replicas"] = *d.Spec.Replicas
+		}
+		v.Properties["readyReplicas"] = d.Status.ReadyReplicas
+		for _, pod := range deploymentPods[d.Namespace+"/"+d.Name] {
+			WithToReferences(pod, "contains")(v)
+		}
+		vertices = append(vertices, *v)
+	}
+	vertices = append(vertices, podVertices...)
+
+	for _, svc := range services.Items {
+		clusterIP := svc.Spec.ClusterIP
+		if clusterIP == corev1.ClusterIPNone {
+			clusterIP = ""
+		}
+		v := s.vertex("Service", svc.ObjectMeta, clusterIP)
+		v.Properties["type"] = string(svc.Spec.Type)
+		if len(svc.Spec.Selector) > 0 {
+			selector := labels.SelectorFromSet(svc.Spec.Selector)
+			for _, p := range pods.Items {
+				if p.Namespace == svc.Namespace && selector.Matches(labels.Set(p.Labels)) {
+					WithToReferences(s.UniqueId("Pod", p.Namespace, p.Name), "exposes")(v)
+				}
+			}
+		}
+		vertices = append(vertices, *v)
+	}
+	return vertices, nil
+}
+
+func controllerOf(owners []metav1.OwnerReference, kind string) string {
+	for _, o := range owners {
+		if o.Kind == kind && o.Controller != nil && *o.Controller {
+			return o.Name
+		}
+	}
+	return ""
+}
+
+func nodeReady(n *corev1.Node) bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package waiops
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubeTopologySource(t *testing.T) {
+	controller := true
+	labels := map[string]string{"app": "web"}
+	client := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker1"},
+			Status: corev1.NodeStatus{
+				Addresses:  []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.11"}, {Type: corev1.NodeHostName, Address: "worker1"}},
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", Labels: labels}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Name: "web-5d8f", Namespace: "shop",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &controller}},
+		}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "web-5d8f-x1", Namespace: "shop", Labels: labels,
+				OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d8f", Controller: &controller}},
+			},
+			Spec:   corev1.PodSpec{NodeName: "worker1"},
+			Status: corev1.PodStatus{PodIP: "10.128.0.5", Phase: corev1.PodRunning},
+		},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "shop"}},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec:       corev1.ServiceSpec{Selector: labels, ClusterIP: "172.30.0.10"},
+		},
+	)
+
+	source := NewKubeTopologySource(client, "ocp1")
+	vertices, err := source.Vertices(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	byId := map[string]Vertex{}
+	for _, v := range vertices {
+		byId[v.UniqueId] = v
+	}
+	if len(byId) != 5 {
+		t.Fatalf("expect 5 vertices, got %v", vertices)
+	}
+
+	pod := "ocp1/pod/shop/web-5d8f-x1"
+	for from, ref := range map[string]Reference{
+		"ocp1/deployment/shop/web": {ToUniqueId: pod, EdgeType: "contains"},
+		pod:                        {ToUniqueId: "ocp1/node/worker1", EdgeType: "runsOn"},
+		"ocp1/service/shop/web":    {ToUniqueId: pod, EdgeType: "exposes"},
+	} {
+		if !slices.Contains(byId[from].References, ref) {
+			t.Errorf("%s: expect reference %+v, got %+v", from, ref, byId[from].References)
+		}
+	}
+
+	node := byId["ocp1/node/worker1"]
+	if !slices.Equal(node.MatchTokens, []string{"worker1", "10.0.0.11"}) || node.Properties["ready"] != true {
+		t.Fatalf("unexpected node %+v", node)
+	}
+	if !slices.Contains(byId[pod].MatchTokens, "10.128.0.5") || len(byId["ocp1/pod/shop/db-0"].References) != 0 {
+		t.Fatalf("unexpected pods %+v", vertices)
+	}
+}
@@ -3,15 +3,37 @@ package waiops
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"slices"
 
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
 	"github.com/twmb/franz-go/pkg/sasl/scram"
 )
 
+// Topics used by the AIOps kafka integrations
+const (
+	AIOpsTopicPrefix = "cp4waiops-cartridge."
+
+	TopicLifecycleInputEvents = "cp4waiops-cartridge.lifecycle.input.events"
+	TopicReplayAlerts         = "cp4waiops-cartridge.irdatalayer.replay.alerts"
+	TopicRequestsAlerts       = "cp4waiops-cartridge.irdatalayer.requests.alerts"
+	TopicRequestsIncidents    = "cp4waiops-cartridge.irdatalayer.requests.incidents"
+)
+
+var AIOpsTopics = []string{
+	TopicLifecycleInputEvents,
+	TopicReplayAlerts,
+	TopicRequestsAlerts,
+	TopicRequestsIncidents,
+}
+
 func tlsConfigWithRootCA(pemFile string) (*tls.Config, error) {
 	rootCA, err := x509.SystemCertPool()
 	if err != nil {
@@ -74,6 +96,158 @@ func NewSASL512Client(caPemFile, sasl_user, sals_pass string, opts ...kgo.Opt) (
 	return client, nil
 }
 
+const (
+	SASLNone        = ""
+	SASLPlain       = "PLAIN"
+	SASLScramSha256 = "SCRAM-SHA-256"
+	SASLScramSha512 = "SCRAM-SHA-512"
+)
+
+// KafkaConfig covers the plaintext, TLS and mTLS setups with or without SASL.
+// Certificates can be given either as file paths or as PEM bytes.
+type KafkaConfig struct {
+	Brokers []string
+
+	SASLMechanism string //one of the SASL constants, empty for no authentication
+	User          string
+	Password      string
+
+	TLS                bool
+	InsecureSkipVerify bool
+	CAFile             string
+	CAPem              []byte
+
+	//client certificate for mTLS
+	CertFile string
+	KeyFile  string
+	CertPem  []byte
+	KeyPem   []byte
+}
+
+// Validate reports all the problems of the config at once
+func (c KafkaConfig) Validate() error {
+	errs := []error{}
+
+	if len(c.Brokers) == 0 {
+		errs = append(errs, fmt.Errorf("no brokers"))
+	}
+	for _, b := range c.Brokers {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			errs = append(errs, fmt.Errorf("invalid broker %q: %w", b, err))
+		}
+	}
+
+	if !slices.Contains([]string{SASLNone, SASLPlain, SASLScramSha256, SASLScramSha512}, c.SASLMechanism) {
+		errs = append(errs, fmt.Errorf("unsupported sasl mechanism: %s", c.SASLMechanism))
+	}
+	if c.SASLMechanism != SASLNone && (c.User == "" || c.Password == "") {
+		errs = append(errs, fmt.Errorf("sasl %s requires user and password", c.SASLMechanism))
+	}
+
+	if c.CAFile != "" && len(c.CAPem) > 0 {
+		errs = append(errs, fmt.Errorf("only one of CAFile and CAPem can be set"))
+	}
+	if (c.CertFile != "" || c.KeyFile != "") && (len(c.CertPem) > 0 || len(c.KeyPem) > 0) {
+		errs = append(errs, fmt.Errorf("client certificate must be given either as files or as PEM"))
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") || (len(c.CertPem) == 0) != (len(c.KeyPem) == 0) {
+		errs = append(errs, fmt.Errorf("client certificate and key must be set together"))
+	}
+	if !c.TLS && (c.CAFile != "" || len(c.CAPem) > 0 || c.isMTLS() || c.InsecureSkipVerify) {
+		errs = append(errs, fmt.Errorf("certificates are configured but TLS is disabled"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c KafkaConfig) isMTLS() bool {
+	return c.CertFile != "" || len(c.CertPem) > 0
+}
+
+// TLSConfig returns nil when TLS is disabled
+func (c KafkaConfig) TLSConfig() (*tls.Config, error) {
+	if !c.TLS {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+
+	caPem := c.CAPem
+	if c.CAFile != "" {
+		var err error
+		caPem, err = os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the ca: %w", err)
+		}
+	}
+	if len(caPem) > 0 {
+		rootCA, err := x509.SystemCertPool()
+		if err != nil || rootCA == nil {
+			rootCA = x509.NewCertPool()
+		}
+		if !rootCA.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificate found in the ca pem")
+		}
+		tlsConfig.RootCAs = rootCA
+	}
+
+	if c.isMTLS() {
+		var cert tls.Certificate
+		var err error
+		if c.CertFile != "" {
+			cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		} else {
+			cert, err = tls.X509KeyPair(c.CertPem, c.KeyPem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (c KafkaConfig) saslMechanism() sasl.Mechanism {
+	switch c.SASLMechanism {
+	case SASLPlain:
+		return plain.Auth{User: c.User, Pass: c.Password}.AsMechanism()
+	case SASLScramSha256:
+		return scram.Auth{User: c.User, Pass: c.Password}.AsSha256Mechanism()
+	case SASLScramSha512:
+		return scram.Auth{User: c.User, Pass: c.Password}.AsSha512Mechanism()
+	}
+	return nil
+}
+
+// NewKafkaClient validates the config before creating the client. Extra options are applied last.
+func NewKafkaClient(config KafkaConfig, opts ...kgo.Opt) (*kgo.Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka config: %w", err)
+	}
+
+	myOpts := []kgo.Opt{kgo.SeedBrokers(config.Brokers...)}
+
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		myOpts = append(myOpts, kgo.DialTLSConfig(tlsConfig))
+	}
+	if mechanism := config.saslMechanism(); mechanism != nil {
+		myOpts = append(myOpts, kgo.SASL(mechanism))
+	}
+	myOpts = append(myOpts, opts...)
+
+	client, err := kgo.NewClient(myOpts...)
+	if err != nil {
+		log.Printf("Failed to create kafka client:%v", err)
+		return nil, err
+	}
+	return client, nil
+}
+
 func AdminClient(kClient *kgo.Client) *kadm.Client {
 	return kadm.NewClient(kClient)
 }
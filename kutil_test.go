@@ -0,0 +1,230 @@
+package waiops
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+)
+
+// testPKI is a CA with a server certificate for localhost and a client certificate
+type testPKI struct {
+	CAPem, CertPem, KeyPem []byte //the CA and the client certificate
+	Server                 tls.Certificate
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "waiops-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDer)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+			DNSNames:     []string{"localhost"},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	}
+
+	pki := testPKI{CAPem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer})}
+	serverCert, serverKey := issue(2, x509.ExtKeyUsageServerAuth)
+	pki.Server, err = tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki.CertPem, pki.KeyPem = issue(3, x509.ExtKeyUsageClientAuth)
+	return pki
+}
+
+// mustPing fails the test unless the client built from the config can reach the cluster
+func mustPing(t *testing.T, config KafkaConfig) {
+	t.Helper()
+	client, err := NewKafkaClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustNotPing fails the test if the client built from the config can reach the cluster
+func mustNotPing(t *testing.T, config KafkaConfig) {
+	t.Helper()
+	client, err := NewKafkaClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx); err == nil {
+		t.Fatal("expected the connection to fail")
+	}
+}
+
+func newTestCluster(t *testing.T, opts ...kfake.Opt) []string {
+	t.Helper()
+	cluster, err := kfake.NewCluster(append([]kfake.Opt{kfake.NumBrokers(1)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+	return cluster.ListenAddrs()
+}
+
+func TestNewKafkaClientPlaintextScram256(t *testing.T) {
+	brokers := newTestCluster(t, kfake.EnableSASL(), kfake.Superuser(SASLScramSha256, "dev", "dev-pass"))
+
+	mustPing(t, KafkaConfig{Brokers: brokers, SASLMechanism: SASLScramSha256, User: "dev", Password: "dev-pass"})
+	mustNotPing(t, KafkaConfig{Brokers: brokers, SASLMechanism: SASLScramSha256, User: "dev", Password: "wrong"})
+}
+
+func TestNewKafkaClientPlain(t *testing.T) {
+	brokers := newTestCluster(t, kfake.EnableSASL(), kfake.Superuser(SASLPlain, "dev", "dev-pass"))
+
+	mustPing(t, KafkaConfig{Brokers: brokers, SASLMechanism: SASLPlain, User: "dev", Password: "dev-pass"})
+	mustNotPing(t, KafkaConfig{Brokers: brokers, SASLMechanism: SASLPlain, User: "dev", Password: "wrong"})
+}
+
+func TestNewKafkaClientScram512OverTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	brokers := newTestCluster(t,
+		kfake.TLS(&tls.Config{Certificates: []tls.Certificate{pki.Server}}),
+		kfake.EnableSASL(),
+		kfake.Superuser(SASLScramSha512, "prod", "prod-pass"),
+	)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pki.CAPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := KafkaConfig{Brokers: brokers, SASLMechanism: SASLScramSha512, User: "prod", Password: "prod-pass", TLS: true}
+
+	withFile := config
+	withFile.CAFile = caFile
+	mustPing(t, withFile)
+
+	withPem := config
+	withPem.CAPem = pki.CAPem
+	mustPing(t, withPem)
+
+	//the server certificate is not trusted without the ca
+	mustNotPing(t, config)
+}
+
+func TestNewKafkaClientMTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(pki.CAPem)
+	brokers := newTestCluster(t, kfake.TLS(&tls.Config{
+		Certificates: []tls.Certificate{pki.Server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}))
+
+	config := KafkaConfig{Brokers: brokers, TLS: true, CAPem: pki.CAPem}
+
+	withPem := config
+	withPem.CertPem, withPem.KeyPem = pki.CertPem, pki.KeyPem
+	mustPing(t, withPem)
+
+	dir := t.TempDir()
+	withFiles := config
+	withFiles.CertFile, withFiles.KeyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	os.WriteFile(withFiles.CertFile, pki.CertPem, 0600)
+	os.WriteFile(withFiles.KeyFile, pki.KeyPem, 0600)
+	mustPing(t, withFiles)
+
+	//the broker requires a client certificate
+	mustNotPing(t, config)
+}
+
+func TestKafkaConfigTLSConfig(t *testing.T) {
+	if tlsConfig, err := (KafkaConfig{CAPem: []byte("pem")}).TLSConfig(); tlsConfig != nil || err != nil {
+		t.Fatalf("expect no TLS config when TLS is disabled, got %v %v", tlsConfig, err)
+	}
+	if _, err := (KafkaConfig{TLS: true, CAPem: []byte("not a pem")}).TLSConfig(); err == nil {
+		t.Fatal("expect an error for an invalid ca pem")
+	}
+	if _, err := (KafkaConfig{TLS: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")}).TLSConfig(); err == nil {
+		t.Fatal("expect an error for a missing ca file")
+	}
+	pki := newTestPKI(t)
+	if _, err := (KafkaConfig{TLS: true, CertPem: pki.CertPem, KeyPem: pki.CAPem}).TLSConfig(); err == nil {
+		t.Fatal("expect an error for a key not matching the certificate")
+	}
+}
+
+func TestKafkaConfigValidateReportsAllProblems(t *testing.T) {
+	err := KafkaConfig{
+		Brokers:       []string{"no-port"},
+		SASLMechanism: "GSSAPI",
+		CAPem:         []byte("pem"),
+	}.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	for _, want := range []string{"invalid broker", "unsupported sasl mechanism", "TLS is disabled"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+
+	if _, err := NewKafkaClient(KafkaConfig{Brokers: []string{"localhost:9092"}, SASLMechanism: SASLPlain}); err == nil {
+		t.Fatal("expected NewKafkaClient to reject a config without user")
+	}
+}
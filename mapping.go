@@ -0,0 +1,680 @@
+package waiops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"sigs.k8s.io/yaml"
+)
+
+// EventMapping turns an arbitrary JSON document, e.g. a webhook payload, into an event.
+// The fields are keyed by the event path, e.g. summary, severity, type.eventType, resource.hostname or details.team.
+//
+//	fields:
+//	  summary: $.alert.title & " on " & $.host
+//	  resource.hostname: $.host ?? $.labels.instance
+//	  severity:
+//	    expr: $.priority
+//	    lookup: {P1: "6", P2: "5"}
+//	    default: "3"
+//
+// The expressions are a JSONata-like subset: paths from the document root $ with .name, ["name"] and [index] steps,
+// negative indexes from the end, "string" literals with Go escapes and number literals, & to concatenate, ?? for the first non-empty value,
+// parentheses and the functions $uppercase, $lowercase, $trim, $string, $number and $join(array, separator).
+type EventMapping struct {
+	Fields map[string]FieldMapping `json:"fields"`
+}
+
+type FieldMapping struct {
+	Expr    string            `json:"expr"`
+	Lookup  map[string]string `json:"lookup,omitempty"`  //replaces the value found, a value missing from the lookup is kept
+	Default string            `json:"default,omitempty"` //when the expression gives no value
+
+	compiled   mappingExpr
+	compileErr error
+}
+
+// UnmarshalJSON accepts a plain expression as a shorthand
+func (f *FieldMapping) UnmarshalJSON(b []byte) error {
+	var expr string
+	if err := json.Unmarshal(b, &expr); err == nil {
+		*f = FieldMapping{Expr: expr}
+		return nil
+	}
+	type plain FieldMapping
+	return json.Unmarshal(b, (*plain)(f))
+}
+
+// LoadEventMapping reads and compiles a mapping file. On invalid expressions the mapping is returned along with
+// the compile errors, its Test reports them next to the fields that evaluate and Apply fails.
+func LoadEventMapping(file string) (*EventMapping, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := &EventMapping{}
+	if err := yaml.UnmarshalStrict(content, m); err != nil {
+		return nil, fmt.Errorf("invalid mapping file %s: %w", file, err)
+	}
+	return m, m.Compile()
+}
+
+// Compile parses every expression, keeping the error of an invalid one on its field and returning them all
+func (m *EventMapping) Compile() error {
+	errs := []error{}
+	for _, name := range m.fieldNames() {
+		f := m.Fields[name]
+		f.compiled, f.compileErr = compileMappingExpr(f.Expr)
+		if f.compileErr != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, f.compileErr))
+		}
+		m.Fields[name] = f
+	}
+	return errors.Join(errs...)
+}
+
+func (m *EventMapping) fieldNames() []string {
+	names := make([]string, 0, len(m.Fields))
+	for name := range m.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// FieldResult is the outcome of one field of the mapping
+type FieldResult struct {
+	Field     string
+	Expr      string
+	Value     any
+	Defaulted bool
+	Err       error
+}
+
+// MappingResult is what the test runner shows: the event, every field outcome and the validation violations
+type MappingResult struct {
+	Event      EvEvent
+	Fields     []FieldResult
+	Violations []string
+}
+
+// Failed lists the fields whose expression or assignment failed
+func (r MappingResult) Failed() []FieldResult {
+	failed := []FieldResult{}
+	for _, f := range r.Fields {
+		if f.Err != nil {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// Report prints the fields outcome, the event and the violations
+func (r MappingResult) Report(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tEXPRESSION\tVALUE\tSTATUS")
+	for _, f := range r.Fields {
+		status := "ok"
+		switch {
+		case f.Err != nil:
+			status = "error: " + f.Err.Error()
+		case f.Defaulted:
+			status = "default"
+		case f.Value == nil:
+			status = "no value"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Field, f.Expr, mappingString(f.Value), status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(r.Event, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%s\n", content)
+	for _, v := range r.Violations {
+		fmt.Fprintf(w, "violation: %s\n", v)
+	}
+	return nil
+}
+
+// Test maps the document, carrying on after a failed field, and validates the event
+func (m *EventMapping) Test(doc []byte) MappingResult {
+	result := MappingResult{Event: EvEvent{
+		Id:             gofakeit.UUID(),
+		OccurrenceTime: EvTime(time.Now()),
+		Resource:       EvResource{Extras: map[string]any{}},
+		Sender:         EvResource{Extras: map[string]any{}},
+		Details:        map[string]string{},
+	}}
+
+	var root any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		result.Fields = append(result.Fields, FieldResult{Field: "$", Err: fmt.Errorf("invalid document: %w", err)})
+		return result
+	}
+
+	for _, name := range m.fieldNames() {
+		f := m.Fields[name]
+		fr := FieldResult{Field: name, Expr: f.Expr}
+		fr.Value, fr.Defaulted, fr.Err = f.eval(root)
+		if fr.Err == nil && fr.Value != nil {
+			fr.Err = setEventField(&result.Event, name, fr.Value)
+		}
+		result.Fields = append(result.Fields, fr)
+	}
+
+	if err := result.Event.Validate(); err != nil {
+		result.Violations = Violations(err)
+	}
+	return result
+}
+
+// Apply maps the document, failing on the first field in error or an invalid event
+func (m *EventMapping) Apply(doc []byte) (EvEvent, error) {
+	result := m.Test(doc)
+	if failed := result.Failed(); len(failed) > 0 {
+		return result.Event, fmt.Errorf("%s: %w", failed[0].Field, failed[0].Err)
+	}
+	if len(result.Violations) > 0 {
+		return result.Event, &ValidationError{Violations: result.Violations}
+	}
+	return result.Event, nil
+}
+
+func (f FieldMapping) eval(root any) (any, bool, error) {
+	if f.compileErr != nil {
+		return nil, false, f.compileErr
+	}
+	compiled := f.compiled
+	if compiled == nil {
+		var err error
+		if compiled, err = compileMappingExpr(f.Expr); err != nil {
+			return nil, false, err
+		}
+	}
+
+	v, err := compiled.eval(root)
+	if err != nil {
+		return nil, false, err
+	}
+	if v == nil || v == "" {
+		if f.Default == "" {
+			return nil, false, nil
+		}
+		return f.Default, true, nil
+	}
+	if f.Lookup != nil {
+		if mapped, ok := f.Lookup[mappingString(v)]; ok {
+			return mapped, false, nil
+		}
+	}
+	return v, false, nil
+}
+
+func setEventField(e *EvEvent, path string, v any) error {
+	switch path {
+	case "id":
+		e.Id = mappingString(v)
+	case "summary":
+		e.Summary = mappingString(v)
+	case "severity", "expirySeconds":
+		n, err := mappingNumber(v)
+		if err != nil {
+			return err
+		}
+		if path == "severity" {
+			e.Severity = int(n)
+		} else {
+			e.ExpirySeconds = int(n)
+		}
+	case "occurrenceTime":
+		content, _ := json.Marshal(v)
+		return e.OccurrenceTime.UnmarshalJSON(content)
+	case "type.classification":
+		e.Type.Classification = mappingString(v)
+	case "type.eventType":
+		e.Type.EventType = mappingString(v)
+	case "type.condition":
+		e.Type.Condition = mappingString(v)
+	default:
+		prefix, key, ok := strings.Cut(path, ".")
+		if !ok || key == "" {
+			return fmt.Errorf("unknown event field %s", path)
+		}
+		switch prefix {
+		case "details":
+			e.Details[key] = mappingString(v)
+		case "resource":
+			return setResourceField(&e.Resource, key, v)
+		case "sender":
+			return setResourceField(&e.Sender, key, v)
+		default:
+			return fmt.Errorf("unknown event field %s", path)
+		}
+	}
+	return nil
+}
+
+// setResourceField sets a known field, any other key goes to Extras
+func setResourceField(r *EvResource, key string, v any) error {
+	if key == "port" {
+		port, ok := parsePort(v)
+		if !ok {
+			return fmt.Errorf("invalid port %v", v)
+		}
+		r.Port = port
+		return nil
+	}
+	if field, ok := r.stringFields()[key]; ok {
+		*field = mappingString(v)
+		return nil
+	}
+	if r.Extras == nil {
+		r.Extras = map[string]any{}
+	}
+	r.Extras[key] = v
+	return nil
+}
+
+func mappingString(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	content, _ := json.Marshal(v)
+	return string(content)
+}
+
+func mappingNumber(v any) (float64, error) {
+	switch value := v.(type) {
+	case float64:
+		return value, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", value)
+		}
+		return n, nil
+	case bool:
+		if value {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%s is not a number", mappingString(v))
+}
+
+// expressions, evaluated against the decoded document. A missing path gives nil, not an error.
+type mappingExpr interface {
+	eval(root any) (any, error)
+}
+
+type literalExpr struct{ value any }
+
+func (e literalExpr) eval(any) (any, error) { return e.value, nil }
+
+type pathExpr struct {
+	steps []any //string for a key, int for an index
+}
+
+func (e pathExpr) eval(root any) (any, error) {
+	v := root
+	for _, step := range e.steps {
+		switch s := step.(type) {
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, nil
+			}
+			v = obj[s]
+		case int:
+			arr, ok := v.([]any)
+			if !ok {
+				return nil, nil
+			}
+			if s < 0 {
+				s += len(arr)
+			}
+			if s < 0 || s >= len(arr) {
+				return nil, nil
+			}
+			v = arr[s]
+		}
+	}
+	return v, nil
+}
+
+type concatExpr struct{ parts []mappingExpr }
+
+func (e concatExpr) eval(root any) (any, error) {
+	var sb strings.Builder
+	for _, p := range e.parts {
+		v, err := p.eval(root)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(mappingString(v))
+	}
+	return sb.String(), nil
+}
+
+type coalesceExpr struct{ parts []mappingExpr }
+
+func (e coalesceExpr) eval(root any) (any, error) {
+	for _, p := range e.parts {
+		v, err := p.eval(root)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil && v != "" {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+type callExpr struct {
+	name string
+	args []mappingExpr
+}
+
+var mappingFuncs = map[string]int{"uppercase": 1, "lowercase": 1, "trim": 1, "string": 1, "number": 1, "join": 2}
+
+func (e callExpr) eval(root any) (any, error) {
+	args := make([]any, len(e.args))
+	for i, a := range e.args {
+		v, err := a.eval(root)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	if args[0] == nil {
+		return nil, nil
+	}
+
+	switch e.name {
+	case "uppercase":
+		return strings.ToUpper(mappingString(args[0])), nil
+	case "lowercase":
+		return strings.ToLower(mappingString(args[0])), nil
+	case "trim":
+		return strings.Join(strings.Fields(mappingString(args[0])), " "), nil
+	case "string":
+		return mappingString(args[0]), nil
+	case "number":
+		n, err := mappingNumber(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("$number: %w", err)
+		}
+		return n, nil
+	case "join":
+		arr, ok := args[0].([]any)
+		if !ok {
+			return nil, fmt.Errorf("$join: %s is not an array", mappingString(args[0]))
+		}
+		s := make([]string, len(arr))
+		for i, v := range arr {
+			s[i] = mappingString(v)
+		}
+		return strings.Join(s, mappingString(args[1])), nil
+	}
+	return nil, fmt.Errorf("unknown function $%s", e.name)
+}
+
+// mappingParser is a recursive descent parser of
+//
+//	expr     = concat { "??" concat }
+//	concat   = term { "&" term }
+//	term     = string | number | path | call | "(" expr ")"
+//	path     = "$" { "." name | "[" ( string | integer ) "]" }
+//	call     = "$" name "(" [ expr { "," expr } ] ")"
+type mappingParser struct {
+	s   string
+	pos int
+}
+
+func compileMappingExpr(s string) (mappingExpr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("empty expression")
+	}
+	p := &mappingParser{s: s}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return e, nil
+}
+
+func (p *mappingParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *mappingParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *mappingParser) accept(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *mappingParser) expr() (mappingExpr, error) {
+	parts := []mappingExpr{}
+	for {
+		e, err := p.concat()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, e)
+		if !p.accept("??") {
+			break
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return coalesceExpr{parts}, nil
+}
+
+func (p *mappingParser) concat() (mappingExpr, error) {
+	parts := []mappingExpr{}
+	for {
+		e, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, e)
+		if !p.accept("&") {
+			break
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return concatExpr{parts}, nil
+}
+
+func (p *mappingParser) term() (mappingExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	switch c := p.s[p.pos]; {
+	case c == '"' || c == '\'':
+		s, err := p.stringLiteral()
+		return literalExpr{s}, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.s) && (p.s[p.pos] == '.' || (p.s[p.pos] >= '0' && p.s[p.pos] <= '9')) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.s[start:p.pos])
+		}
+		return literalExpr{n}, nil
+	case c == '(':
+		p.pos++
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return e, nil
+	case c == '$':
+		p.pos++
+		if name := p.name(); name != "" {
+			return p.call(name)
+		}
+		return p.path()
+	}
+	return nil, p.errorf("unexpected %q", p.s[p.pos:])
+}
+
+func (p *mappingParser) name() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := rune(p.s[p.pos])
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '-' {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// stringLiteral decodes the escapes of a single or double quoted string like a Go string
+func (p *mappingParser) stringLiteral() (string, error) {
+	quote := p.s[p.pos]
+	var sb strings.Builder
+	for i := p.pos + 1; i < len(p.s); i++ {
+		switch c := p.s[i]; {
+		case c == '\\' && i+1 < len(p.s):
+			i++
+			if p.s[i] == '\'' {
+				sb.WriteByte('\'')
+			} else {
+				sb.WriteByte(c)
+				sb.WriteByte(p.s[i])
+			}
+		case c == quote:
+			s, err := strconv.Unquote(`"` + sb.String() + `"`)
+			if err != nil {
+				return "", p.errorf("invalid string %s", p.s[p.pos:i+1])
+			}
+			p.pos = i + 1
+			return s, nil
+		case c == '"':
+			sb.WriteString(`\"`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *mappingParser) path() (mappingExpr, error) {
+	e := pathExpr{}
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case '.':
+			p.pos++
+			name := p.name()
+			if name == "" {
+				return nil, p.errorf("missing name after .")
+			}
+			e.steps = append(e.steps, name)
+		case '[':
+			p.pos++
+			p.skipSpace()
+			if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+				key, err := p.stringLiteral()
+				if err != nil {
+					return nil, err
+				}
+				e.steps = append(e.steps, key)
+			} else {
+				start := p.pos
+				for p.pos < len(p.s) && p.s[p.pos] != ']' {
+					p.pos++
+				}
+				index, err := strconv.Atoi(strings.TrimSpace(p.s[start:p.pos]))
+				if err != nil {
+					return nil, p.errorf("invalid index %q", p.s[start:p.pos])
+				}
+				e.steps = append(e.steps, index)
+			}
+			if !p.accept("]") {
+				return nil, p.errorf("missing ]")
+			}
+		default:
+			return e, nil
+		}
+	}
+	return e, nil
+}
+
+func (p *mappingParser) call(name string) (mappingExpr, error) {
+	arity, ok := mappingFuncs[name]
+	if !ok {
+		return nil, p.errorf("unknown function $%s", name)
+	}
+	if !p.accept("(") {
+		return nil, p.errorf("missing ( after $%s", name)
+	}
+
+	e := callExpr{name: name}
+	if !p.accept(")") {
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			e.args = append(e.args, arg)
+			if p.accept(")") {
+				break
+			}
+			if !p.accept(",") {
+				return nil, p.errorf("missing , or )")
+			}
+		}
+	}
+	if len(e.args) != arity {
+		return nil, p.errorf("$%s takes %d argument(s), got %d", name, arity, len(e.args))
+	}
+	return e, nil
+}
@@ -0,0 +1,138 @@
+package waiops
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const webhookPayload = `{
+  "alert": {"title": "High CPU", "priority": "P2", "tags": ["prod", "web"]},
+  "host": "web01",
+  "labels": {"instance": "10.0.0.1:9100", "port": "9100"},
+  "events": [{"ts": 1714557600}, {"ts": 1714561200}]
+}`
+
+func TestEventMapping(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mapping.yaml")
+	os.WriteFile(file, []byte(`
+fields:
+  summary: $.alert.title & " on " & $uppercase($.host)
+  resource.hostname: $.hostname ?? $.host
+  resource.port: $.labels["port"]
+  resource.zone: $.labels.zone ?? "eu-1"
+  occurrenceTime: $.events[-1].ts
+  type.classification: "'CPU'"
+  type.eventType:
+    expr: $.state
+    default: problem
+  severity:
+    expr: $.alert.priority
+    lookup: {P1: "6", P2: "5"}
+  details.tags: $join($.alert.tags, ",")
+`), 0600)
+
+	mapping, err := LoadEventMapping(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := mapping.Apply([]byte(webhookPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Summary != "High CPU on WEB01" || e.Resource.Hostname != "web01" || e.Resource.Port != 9100 || e.Resource.Extras["zone"] != "eu-1" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if e.Severity != 5 || e.Type.EventType != "problem" || e.Type.Classification != "CPU" || e.Details["tags"] != "prod,web" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if !time.Time(e.OccurrenceTime).Equal(time.Unix(1714561200, 0)) {
+		t.Fatalf("unexpected occurrence time %v", time.Time(e.OccurrenceTime))
+	}
+}
+
+func TestEventMappingReport(t *testing.T) {
+	mapping := &EventMapping{Fields: map[string]FieldMapping{
+		"summary":             {Expr: "$.alert.title"},
+		"severity":            {Expr: "$.host"},
+		"resource.hostname":   {Expr: "$.missing"},
+		"type.classification": {Expr: `$lowercase($.alert.title`},
+	}}
+	if err := mapping.Compile(); err == nil || !strings.Contains(err.Error(), "type.classification") {
+		t.Fatalf("expect a compile error for type.classification, got %v", err)
+	}
+
+	result := mapping.Test([]byte(webhookPayload))
+	failed := []string{}
+	for _, f := range result.Failed() {
+		failed = append(failed, f.Field)
+	}
+	if strings.Join(failed, ",") != "severity,type.classification" {
+		t.Fatalf("unexpected failed fields %v", failed)
+	}
+	if len(result.Violations) == 0 {
+		t.Fatal("expect violations of the incomplete event")
+	}
+
+	var buf bytes.Buffer
+	result.Report(&buf)
+	for _, want := range []string{"no value", `"web01" is not a number`, "missing , or )", `"summary": "High CPU"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expect %q in the report\n%s", want, buf.String())
+		}
+	}
+	if _, err := mapping.Apply([]byte(webhookPayload)); err == nil {
+		t.Fatal("expect Apply to fail on the invalid fields")
+	}
+}
+
+func TestLoadEventMappingKeepsCompileErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mapping.yaml")
+	os.WriteFile(file, []byte(`
+fields:
+  summary: $.alert.title
+  resource.hostname: $.host &
+`), 0600)
+
+	mapping, err := LoadEventMapping(file)
+	if err == nil || mapping == nil {
+		t.Fatalf("expect the mapping with the compile error, got %v %v", mapping, err)
+	}
+	result := mapping.Test([]byte(webhookPayload))
+	if failed := result.Failed(); len(failed) != 1 || failed[0].Field != "resource.hostname" {
+		t.Fatalf("unexpected failed fields %+v", failed)
+	}
+	if result.Event.Summary != "High CPU" {
+		t.Fatalf("expect the valid fields evaluated, got %+v", result.Event)
+	}
+}
+
+func TestMappingStringEscapes(t *testing.T) {
+	for expr, want := range map[string]string{
+		`"a\nb"`:           "a\nb",
+		`'it\'s "quoted"'`: `it's "quoted"`,
+		`"tab\there"`:      "tab\there",
+		`"\u00e9t\u00e9"`:  "été",
+		`"back\\slash"`:    `back\slash`,
+	} {
+		e, err := compileMappingExpr(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if v, _ := e.eval(nil); v != want {
+			t.Errorf("%s: expect %q, got %q", expr, want, v)
+		}
+	}
+}
+
+func TestMappingExprErrors(t *testing.T) {
+	for _, expr := range []string{"", "$.", "$.a[x]", "$foo(1)", `"abc`, "$.a & ", "$join($.a)", "($.a", `"\q"`} {
+		if _, err := compileMappingExpr(expr); err == nil {
+			t.Errorf("expect an error for %q", expr)
+		}
+	}
+}
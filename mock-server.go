@@ -0,0 +1,373 @@
+package waiops
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// MockServer is an in memory stand-in of the AIOps REST endpoints, for tests.
+// It checks the same X-TenantID and ZenApiKey headers as the real cluster.
+type MockServer struct {
+	*httptest.Server
+
+	apiUser  string
+	apiKey   string
+	tenantID string
+	zenToken string
+
+	mu        sync.Mutex
+	alerts    []EvAlert
+	incidents []Incident
+	events    []EvEvent
+	vertices  []Vertex
+	metrics   []Metric
+}
+
+func NewMockServer(apiUser, apiKey string) *MockServer {
+	m := &MockServer{
+		apiUser:  apiUser,
+		apiKey:   apiKey,
+		tenantID: defaultTenantID,
+		zenToken: base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", apiUser, apiKey))),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+AlertsUri, m.listAlerts)
+	mux.HandleFunc("POST "+AlertsUri, m.createAlert)
+	mux.HandleFunc("GET "+AlertsUri+"/{id}", m.getAlert)
+	mux.HandleFunc("PATCH "+AlertsUri+"/{id}", m.patchAlert)
+	mux.HandleFunc("DELETE "+AlertsUri+"/{id}", m.deleteAlert)
+
+	mux.HandleFunc("GET "+IncidentsUri, m.listIncidents)
+	mux.HandleFunc("POST "+IncidentsUri, m.createIncident)
+	mux.HandleFunc("GET "+IncidentsUri+"/{id}", m.getIncident)
+	mux.HandleFunc("PATCH "+IncidentsUri+"/{id}", m.patchIncident)
+	mux.HandleFunc("DELETE "+IncidentsUri+"/{id}", m.deleteIncident)
+
+	mux.HandleFunc("POST "+EventsUri, m.postEvent)
+	mux.HandleFunc("GET "+TopologyResourcesUri, m.listVertices)
+	mux.HandleFunc("POST "+TopologyResourcesUri, m.postVertex)
+	mux.HandleFunc("POST "+MetricsUri, m.postMetrics)
+
+	m.Server = httptest.NewServer(m.authenticate(mux))
+	return m
+}
+
+// SetTenantID changes the tenant id expected in the X-TenantID header
+func (m *MockServer) SetTenantID(tenantID string) *MockServer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tenantID = tenantID
+	return m
+}
+
+// API returns a client pointed at the mock server with the matching credentials
+func (m *MockServer) API() *API {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return NewAPI(m.URL, m.apiUser, m.apiKey).SetTenantID(m.tenantID)
+}
+
+func (m *MockServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		tenantID := m.tenantID
+		m.mu.Unlock()
+		if r.Header.Get("X-TenantID") != tenantID {
+			writeMockError(w, http.StatusForbidden, "invalid tenant id")
+			return
+		}
+		if r.Header.Get("Authorization") != "ZenApiKey "+m.zenToken {
+			writeMockError(w, http.StatusUnauthorized, "invalid zen api key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *MockServer) SeedAlerts(alerts ...EvAlert) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alerts = append(m.alerts, alerts...)
+}
+
+func (m *MockServer) SeedIncidents(incidents ...Incident) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.incidents = append(m.incidents, incidents...)
+}
+
+func (m *MockServer) SeedEvents(events ...EvEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, events...)
+}
+
+// SeedVertices replaces the vertices of the same uniqueId, as the topology observer does
+func (m *MockServer) SeedVertices(vertices ...Vertex) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range vertices {
+		m.upsertVertex(v)
+	}
+}
+
+func (m *MockServer) SeedMetrics(metrics ...Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics = append(m.metrics, metrics...)
+}
+
+func (m *MockServer) Alerts() []EvAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.alerts)
+}
+
+func (m *MockServer) Incidents() []Incident {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.incidents)
+}
+
+func (m *MockServer) Events() []EvEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.events)
+}
+
+func (m *MockServer) Vertices() []Vertex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.vertices)
+}
+
+func (m *MockServer) Metrics() []Metric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.metrics)
+}
+
+// Reset drops everything seeded or received so far
+func (m *MockServer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alerts = nil
+	m.incidents = nil
+	m.events = nil
+	m.vertices = nil
+	m.metrics = nil
+}
+
+func (m *MockServer) listAlerts(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, map[string]any{"alerts": mockPage(m.alerts, r)})
+}
+
+func (m *MockServer) createAlert(w http.ResponseWriter, r *http.Request) {
+	var alert EvAlert
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		writeMockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if alert.Id == "" {
+		alert.Id = gofakeit.UUID()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alerts = append(m.alerts, alert)
+	writeMockJSON(w, http.StatusCreated, alert)
+}
+
+func (m *MockServer) getAlert(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.alerts, func(a EvAlert) bool { return a.Id == r.PathValue("id") })
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "alert not found")
+		return
+	}
+	writeMockJSON(w, http.StatusOK, m.alerts[i])
+}
+
+func (m *MockServer) patchAlert(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.alerts, func(a EvAlert) bool { return a.Id == r.PathValue("id") })
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "alert not found")
+		return
+	}
+	// decoding on top of the stored alert only overwrites the fields present in the patch
+	alert := m.alerts[i]
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		writeMockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	alert.Id = m.alerts[i].Id
+	m.alerts[i] = alert
+	writeMockJSON(w, http.StatusOK, alert)
+}
+
+func (m *MockServer) deleteAlert(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.alerts, func(a EvAlert) bool { return a.Id == r.PathValue("id") })
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "alert not found")
+		return
+	}
+	m.alerts = slices.Delete(m.alerts, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *MockServer) listIncidents(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, map[string]any{"incidents": mockPage(m.incidents, r)})
+}
+
+func (m *MockServer) createIncident(w http.ResponseWriter, r *http.Request) {
+	var incident Incident
+	if err := json.NewDecoder(r.Body).Decode(&incident); err != nil {
+		writeMockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if incident.ID == "" {
+		incident.ID = gofakeit.UUID()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.incidents = append(m.incidents, incident)
+	writeMockJSON(w, http.StatusCreated, incident)
+}
+
+func (m *MockServer) getIncident(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.incidents, func(inc Incident) bool { return inc.ID == r.PathValue("id") })
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "incident not found")
+		return
+	}
+	writeMockJSON(w, http.StatusOK, m.incidents[i])
+}
+
+func (m *MockServer) patchIncident(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.incidents, func(inc Incident) bool { return inc.ID == r.PathValue("id") })
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "incident not found")
+		return
+	}
+	incident := m.incidents[i]
+	if err := json.NewDecoder(r.Body).Decode(&incident); err != nil {
+		writeMockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	incident.ID = m.incidents[i].ID
+	m.incidents[i] = incident
+	writeMockJSON(w, http.StatusOK, incident)
+}
+
+func (m *MockServer) deleteIncident(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.incidents, func(inc Incident) bool { return inc.ID == r.PathValue("id") })
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "incident not found")
+		return
+	}
+	m.incidents = slices.Delete(m.incidents, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *MockServer) postEvent(w http.ResponseWriter, r *http.Request) {
+	var event EvEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		writeMockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	writeMockJSON(w, http.StatusAccepted, map[string]string{"id": event.Id})
+}
+
+func (m *MockServer) listVertices(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, map[string]any{"_items": mockPage(m.vertices, r)})
+}
+
+func (m *MockServer) postVertex(w http.ResponseWriter, r *http.Request) {
+	var vertex Vertex
+	if err := json.NewDecoder(r.Body).Decode(&vertex); err != nil {
+		writeMockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.upsertVertex(vertex)
+	writeMockJSON(w, http.StatusOK, vertex)
+}
+
+// upsertVertex has the InsertReplace semantic, same uniqueId replaces the existing vertex
+func (m *MockServer) upsertVertex(vertex Vertex) {
+	i := slices.IndexFunc(m.vertices, func(v Vertex) bool { return v.UniqueId == vertex.UniqueId })
+	if i >= 0 {
+		m.vertices[i] = vertex
+	} else {
+		m.vertices = append(m.vertices, vertex)
+	}
+}
+
+func (m *MockServer) postMetrics(w http.ResponseWriter, r *http.Request) {
+	var group MetricGroup
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		writeMockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics = append(m.metrics, group.Groups...)
+	writeMockJSON(w, http.StatusOK, map[string]int{"count": len(group.Groups)})
+}
+
+// mockPage applies the limit and offset query params the same way the pager sends them
+func mockPage[T any](items []T, r *http.Request) []T {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = len(items)
+	}
+
+	offset = min(max(offset, 0), len(items))
+	end := min(offset+limit, len(items))
+	return slices.Clone(items[offset:end])
+}
+
+func writeMockJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeMockError(w http.ResponseWriter, status int, msg string) {
+	writeMockJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,154 @@
+package waiops
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestMockServerRejectsWrongCredentials(t *testing.T) {
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+
+	api := NewAPI(server.URL, "admin", "wrong")
+	resp, err := api.CallAPI(AlertsUri, "GET")
+	if err == nil {
+		t.Fatal("expected an error for the wrong api key")
+	}
+	if resp.StatusCode() != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", resp.StatusCode())
+	}
+}
+
+func TestMockServerAlertLifecycle(t *testing.T) {
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+	api := server.API()
+
+	created, err := DoAs[EvAlert](api.NewRequest("POST", AlertsUri).SetBody(NewRandomAlert()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = api.CallAPI(AlertsUri+"/"+created.Id, "PATCH", map[string]string{"state": "clear"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alerts := server.Alerts()
+	if len(alerts) != 1 || alerts[0].State != "clear" {
+		t.Fatalf("expected one cleared alert, got %+v", alerts)
+	}
+
+	if _, err := api.CallAPI(AlertsUri+"/"+created.Id, "DELETE"); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Alerts()) != 0 {
+		t.Fatal("expected the alert to be deleted")
+	}
+}
+
+func TestMockServerRejectsWrongTenant(t *testing.T) {
+	server := NewMockServer("admin", "secret").SetTenantID("t1")
+	defer server.Close()
+
+	resp, err := NewAPI(server.URL, "admin", "secret").CallAPI(AlertsUri, "GET")
+	if err == nil || resp.StatusCode() != http.StatusForbidden {
+		t.Fatalf("expected status 403 for the default tenant, got %v", err)
+	}
+	if _, err := server.API().CallAPI(AlertsUri, "GET"); err != nil {
+		t.Fatalf("expected the client of the server to use its tenant, got %v", err)
+	}
+}
+
+func TestMockServerIncidentLifecycle(t *testing.T) {
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+	api := server.API()
+
+	server.SeedIncidents(Incident{ID: "seeded", Title: "disk full"})
+	created, err := DoAs[Incident](api.NewRequest("POST", IncidentsUri).SetBody(Incident{Title: "cpu high", State: "inProgress"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" {
+		t.Fatal("expected an id for the created incident")
+	}
+
+	got, err := DoAs[Incident](api.NewRequest("GET", IncidentsUri+"/seeded"))
+	if err != nil || got.Title != "disk full" {
+		t.Fatalf("expected the seeded incident, got %+v: %v", got, err)
+	}
+
+	if _, err := api.CallAPI(IncidentsUri+"/"+created.ID, "PATCH", map[string]string{"state": "resolved"}); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	if err := api.IncidentPager(1).Each(func(inc Incident) bool {
+		ids = append(ids, inc.ID)
+		if inc.ID == created.ID && (inc.State != "resolved" || inc.Title != "cpu high") {
+			t.Fatalf("expected the patched incident, got %+v", inc)
+		}
+		return true
+	}); err != nil || len(ids) != 2 {
+		t.Fatalf("expected both incidents listed, got %v: %v", ids, err)
+	}
+
+	if _, err := api.CallAPI(IncidentsUri+"/seeded", "DELETE"); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := api.CallAPI(IncidentsUri+"/seeded", "GET"); err == nil || resp.StatusCode() != http.StatusNotFound {
+		t.Fatalf("expected the deleted incident to be gone, got %v", err)
+	}
+	if incidents := server.Incidents(); len(incidents) != 1 || incidents[0].ID != created.ID {
+		t.Fatalf("unexpected incidents %+v", incidents)
+	}
+}
+
+func TestMockServerEventsTopologyAndMetrics(t *testing.T) {
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+	api := server.API()
+	ctx := context.Background()
+
+	server.SeedEvents(NewRandomEvent())
+	event := NewRandomEvent()
+	if err := api.SendEvent(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if events := server.Events(); len(events) != 2 || events[1].Id != event.Id {
+		t.Fatalf("expected the seeded and the sent event, got %d", len(events))
+	}
+
+	server.SeedVertices(*NewVertex("web01", WithUniqueId("web01")), *NewVertex("db01", WithUniqueId("db01")))
+	if err := api.SendVertex(ctx, *NewVertex("web01-renamed", WithUniqueId("web01"))); err != nil {
+		t.Fatal(err)
+	}
+	vertices, err := DoAs[map[string][]Vertex](api.NewRequest("GET", TopologyResourcesUri).SetQueryParam("limit", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := vertices["_items"]; len(items) != 1 || items[0].Name != "web01-renamed" {
+		t.Fatalf("expected the replaced vertex on the first page, got %+v", items)
+	}
+	if len(server.Vertices()) != 2 {
+		t.Fatalf("expected 2 vertices, got %d", len(server.Vertices()))
+	}
+
+	server.SeedMetrics(Metric{ResourceId: "db01", Metrics: map[string]float64{"iops": 10}})
+	group := MetricGroup{Groups: []Metric{
+		{ResourceId: "web01", Metrics: map[string]float64{"cpu": 0.5}},
+		{ResourceId: "web01", Metrics: map[string]float64{"memory": 0.7}},
+	}}
+	if err := api.SendMetrics(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	if metrics := server.Metrics(); len(metrics) != 3 || metrics[2].Metrics["memory"] != 0.7 {
+		t.Fatalf("expected the seeded and the sent metrics, got %+v", metrics)
+	}
+
+	server.Reset()
+	if len(server.Events()) != 0 || len(server.Vertices()) != 0 || len(server.Metrics()) != 0 {
+		t.Fatal("expected nothing left after a reset")
+	}
+}
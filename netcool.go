@@ -0,0 +1,285 @@
+package waiops
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NetcoolMapping maps the alerts.status columns holding text to the alert fields, by their JSON path,
+// e.g. "resource.hostname". Severity, Type, Tally, Acknowledged, FirstOccurrence and LastOccurrence are
+// converted by NetcoolToAlert itself, the columns not mapped are kept in Details.
+type NetcoolMapping map[string]string
+
+// DefaultNetcoolMapping follows the common use of the columns by the probes
+var DefaultNetcoolMapping = NetcoolMapping{
+	"Identifier": "deduplicationKey",
+	"Node":       "resource.hostname",
+	"NodeAlias":  "resource.ipAddress",
+	"AlertGroup": "type.classification",
+	"AlertKey":   "resource.component",
+	"Summary":    "summary",
+	"Location":   "resource.location",
+	"Manager":    "sender.name",
+	"Agent":      "sender.service",
+}
+
+// NetcoolColumns are the usual alerts.status columns, written first by WriteNetcoolCSV
+var NetcoolColumns = []string{"Identifier", "Node", "NodeAlias", "AlertGroup", "AlertKey", "Severity", "Type", "Tally", "FirstOccurrence", "LastOccurrence", "Summary"}
+
+func (a *EvAlert) stringFields() map[string]*string {
+	fields := map[string]*string{
+		"id":                  &a.Id,
+		"deduplicationKey":    &a.DeduplicationKey,
+		"signature":           &a.Signature,
+		"summary":             &a.Summary,
+		"team":                &a.Team,
+		"owner":               &a.Owner,
+		"langId":              &a.LangId,
+		"type.classification": &a.Type.Classification,
+		"type.condition":      &a.Type.Condition,
+	}
+	for k, v := range a.Resource.stringFields() {
+		fields["resource."+k] = v
+	}
+	for k, v := range a.Sender.stringFields() {
+		fields["sender."+k] = v
+	}
+	return fields
+}
+
+// Validate checks every mapped field exists
+func (m NetcoolMapping) Validate() error {
+	fields := (&EvAlert{}).stringFields()
+	errs := []error{}
+	for column, path := range m {
+		if _, ok := fields[path]; !ok {
+			errs = append(errs, fmt.Errorf("column %s is mapped to the unknown field %s", column, path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// netcool severity: 0 clear, 1 indeterminate, 2 warning, 3 minor, 4 major, 5 critical
+var netcoolToSeverity = []int{1, 1, 3, 4, 5, 6}
+
+// by the 1-6 severity, information has no equivalent and falls back to indeterminate
+var severityToNetcool = []int{1, 1, 1, 2, 3, 4, 5}
+
+// NetcoolToAlert converts an alerts.status row, keyed by the column names.
+// A clear severity gives a clear alert, the Type 2 rows resolutions, the others problems.
+func (m NetcoolMapping) NetcoolToAlert(row map[string]string) (EvAlert, error) {
+	a := EvAlert{
+		State:      "open",
+		EventCount: 1,
+		Resource:   EvResource{Extras: map[string]any{}},
+		Sender:     EvResource{Extras: map[string]any{}},
+		Type:       EvType{EventType: "problem"},
+		Details:    map[string]string{},
+	}
+	fields := a.stringFields()
+
+	for column, value := range row {
+		if path, ok := m[column]; ok {
+			field, ok := fields[path]
+			if !ok {
+				return a, fmt.Errorf("column %s is mapped to the unknown field %s", column, path)
+			}
+			*field = value
+			continue
+		}
+
+		switch column {
+		case "Severity":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 5 {
+				return a, fmt.Errorf("invalid Severity %q", value)
+			}
+			a.Severity = netcoolToSeverity[n]
+			if n == 0 {
+				a.State = "clear"
+			}
+		case "Type":
+			if value == "2" {
+				a.Type.EventType = "resolution"
+			}
+			a.Details["Type"] = value
+		case "Tally":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return a, fmt.Errorf("invalid Tally %q", value)
+			}
+			a.EventCount = n
+		case "Acknowledged":
+			a.Acknowledged = value == "1"
+		case "FirstOccurrence", "LastOccurrence":
+			t, err := parseNetcoolTime(value)
+			if err != nil {
+				return a, fmt.Errorf("invalid %s: %w", column, err)
+			}
+			if column == "FirstOccurrence" {
+				a.FirstOccurrenceTime = EvTime(t)
+			} else {
+				a.LastOccurrenceTime = EvTime(t)
+			}
+		default:
+			if value != "" {
+				a.Details[column] = value
+			}
+		}
+	}
+
+	if a.Resource.Name == "" {
+		a.Resource.Name = a.Resource.Hostname
+	}
+	if a.Type.Condition == "" {
+		a.Type.Condition = a.Type.Classification
+	}
+	if a.Signature == "" {
+		a.Signature = a.DeduplicationKey
+	}
+	if a.Id == "" {
+		a.Id = a.DeduplicationKey
+	}
+	if time.Time(a.FirstOccurrenceTime).IsZero() {
+		a.FirstOccurrenceTime = a.LastOccurrenceTime
+	}
+	a.OccurrenceTime = a.FirstOccurrenceTime
+	a.LastStateChangeTime = a.LastOccurrenceTime
+	return a, nil
+}
+
+// parseNetcoolTime accepts the epoch seconds of the ObjectServer and the date formats of the usual exports
+func parseNetcoolTime(s string) (time.Time, error) {
+	if s == "" || s == "0" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "01/02/06 15:04:05", "01/02/2006 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time %q", s)
+}
+
+// AlertToNetcool is the reverse of NetcoolToAlert. The Details come back as columns.
+func (m NetcoolMapping) AlertToNetcool(a EvAlert) map[string]string {
+	row := map[string]string{}
+	for column, value := range a.Details {
+		row[column] = value
+	}
+
+	fields := a.stringFields()
+	for column, path := range m {
+		if field, ok := fields[path]; ok {
+			row[column] = *field
+		}
+	}
+
+	severity := 0
+	if a.State == "open" {
+		severity = 1
+		if a.Severity >= MinSeverity && a.Severity <= MaxSeverity {
+			severity = severityToNetcool[a.Severity]
+		}
+	}
+	row["Severity"] = strconv.Itoa(severity)
+
+	//the original Type of a problem is kept, e.g. 13 for information
+	switch {
+	case a.Type.EventType == "resolution":
+		row["Type"] = "2"
+	case row["Type"] == "" || row["Type"] == "2":
+		row["Type"] = "1"
+	}
+	row["Tally"] = strconv.Itoa(a.EventCount)
+	row["Acknowledged"] = "0"
+	if a.Acknowledged {
+		row["Acknowledged"] = "1"
+	}
+	for column, t := range map[string]EvTime{"FirstOccurrence": a.FirstOccurrenceTime, "LastOccurrence": a.LastOccurrenceTime} {
+		row[column] = "0"
+		if !time.Time(t).IsZero() {
+			row[column] = strconv.FormatInt(time.Time(t).Unix(), 10)
+		}
+	}
+	return row
+}
+
+// ReadNetcoolCSV converts a CSV export, with the column names on the first line, calling fn for each alert
+func ReadNetcoolCSV(r io.Reader, mapping NetcoolMapping, fn func(EvAlert) error) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("missing header: %w", err)
+	}
+	header = slices.Clone(header)
+	for i, h := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		row := map[string]string{}
+		for i, v := range record {
+			row[header[i]] = v
+		}
+		a, err := mapping.NetcoolToAlert(row)
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteNetcoolCSV writes the alerts as a CSV export, NetcoolColumns first then the other columns sorted by name
+func WriteNetcoolCSV(w io.Writer, mapping NetcoolMapping, alerts []EvAlert) error {
+	rows := []map[string]string{}
+	others := []string{}
+	for _, a := range alerts {
+		row := mapping.AlertToNetcool(a)
+		for column := range row {
+			if !slices.Contains(NetcoolColumns, column) && !slices.Contains(others, column) {
+				others = append(others, column)
+			}
+		}
+		rows = append(rows, row)
+	}
+	slices.Sort(others)
+	header := append(slices.Clone(NetcoolColumns), others...)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, column := range header {
+			record[i] = row[column]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
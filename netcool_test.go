@@ -0,0 +1,85 @@
+package waiops
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const netcoolExport = `Identifier,Node,NodeAlias,AlertGroup,AlertKey,Severity,Type,Tally,FirstOccurrence,LastOccurrence,Summary,ServerSerial
+"router1LinkStatusifEntry.3",router1,10.1.1.1,LinkStatus,ifEntry.3,4,1,3,1714557600,1714561200,"Link Down, ifIndex 3",1001
+"router1LinkStatusifEntry.4",router1,10.1.1.1,LinkStatus,ifEntry.4,0,2,1,2024-05-01 10:00:00,2024-05-01 10:00:00,Link Up,1002
+`
+
+func TestReadNetcoolCSV(t *testing.T) {
+	alerts := []EvAlert{}
+	err := ReadNetcoolCSV(strings.NewReader(netcoolExport), DefaultNetcoolMapping, func(a EvAlert) error {
+		alerts = append(alerts, a)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("expect 2 alerts, got %d", len(alerts))
+	}
+
+	a := alerts[0]
+	if a.DeduplicationKey != "router1LinkStatusifEntry.3" || a.Resource.Hostname != "router1" || a.Resource.Name != "router1" ||
+		a.Resource.IpAddress != "10.1.1.1" || a.Resource.Component != "ifEntry.3" || a.Type.Classification != "LinkStatus" {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+	if a.Severity != 5 || a.State != "open" || a.Type.EventType != "problem" || a.EventCount != 3 || a.Summary != "Link Down, ifIndex 3" {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+	if !time.Time(a.FirstOccurrenceTime).Equal(time.Unix(1714557600, 0)) || a.Details["ServerSerial"] != "1001" {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+
+	a = alerts[1]
+	if a.State != "clear" || a.Type.EventType != "resolution" || !time.Time(a.LastOccurrenceTime).Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+
+	err = ReadNetcoolCSV(strings.NewReader("Identifier,Severity\nx,9\n"), DefaultNetcoolMapping, func(EvAlert) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expect an error on line 2, got %v", err)
+	}
+}
+
+func TestNetcoolRoundTrip(t *testing.T) {
+	alerts := []EvAlert{}
+	ReadNetcoolCSV(strings.NewReader(netcoolExport), DefaultNetcoolMapping, func(a EvAlert) error {
+		alerts = append(alerts, a)
+		return nil
+	})
+
+	var buf bytes.Buffer
+	if err := WriteNetcoolCSV(&buf, DefaultNetcoolMapping, alerts); err != nil {
+		t.Fatal(err)
+	}
+
+	again := []EvAlert{}
+	if err := ReadNetcoolCSV(&buf, DefaultNetcoolMapping, func(a EvAlert) error {
+		again = append(again, a)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range alerts {
+		row, rowAgain := DefaultNetcoolMapping.AlertToNetcool(alerts[i]), DefaultNetcoolMapping.AlertToNetcool(again[i])
+		for column, v := range row {
+			if rowAgain[column] != v {
+				t.Errorf("alert %d column %s: %q != %q", i, column, rowAgain[column], v)
+			}
+		}
+	}
+	if row := DefaultNetcoolMapping.AlertToNetcool(alerts[0]); row["Severity"] != "4" || row["Type"] != "1" || row["Node"] != "router1" {
+		t.Fatalf("unexpected row %v", row)
+	}
+
+	if err := (NetcoolMapping{"Node": "resource.nodename"}).Validate(); err == nil {
+		t.Fatal("expect an error for the unknown field")
+	}
+}
@@ -0,0 +1,134 @@
+package waiops
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Pager walks a list endpoint page by page. Offsets are used unless the response carries a cursor,
+// in which case the cursor is sent back on the next call.
+type Pager[T any] struct {
+	api      *API
+	uri      string
+	itemsKey string //key of the item array in the response, empty if the response is the array itself
+	pageSize int
+	query    map[string]string
+
+	CursorKey   string //response key holding the next cursor
+	CursorParam string //query param used to send the cursor back
+
+	page   int
+	offset int
+	cursor string
+	done   bool
+}
+
+func NewPager[T any](a *API, uri, itemsKey string, pageSize int) *Pager[T] {
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	return &Pager[T]{
+		api:      a,
+		uri:      uri,
+		itemsKey: itemsKey,
+		pageSize: pageSize,
+		query:    map[string]string{},
+
+		CursorKey:   "nextCursor",
+		CursorParam: "cursor",
+	}
+}
+
+func (a *API) AlertPager(pageSize int) *Pager[EvAlert] {
+	return NewPager[EvAlert](a, AlertsUri, "alerts", pageSize)
+}
+
+func (a *API) IncidentPager(pageSize int) *Pager[Incident] {
+	return NewPager[Incident](a, IncidentsUri, "incidents", pageSize)
+}
+
+// SetQueryParam adds a filter sent with every page
+func (p *Pager[T]) SetQueryParam(key, value string) *Pager[T] {
+	p.query[key] = value
+	return p
+}
+
+// NextPage fetches the next page. It returns nil items and nil error once the listing is exhausted.
+func (p *Pager[T]) NextPage() ([]T, error) {
+	if p.done {
+		return nil, nil
+	}
+
+	req := p.api.NewRequest("GET", p.uri).
+		SetQueryParams(p.query).
+		SetQueryParam("limit", strconv.Itoa(p.pageSize))
+	if p.cursor != "" {
+		req.SetQueryParam(p.CursorParam, p.cursor)
+	} else {
+		req.SetQueryParam("offset", strconv.Itoa(p.offset))
+	}
+
+	resp, err := req.Do()
+	if err != nil {
+		p.done = true
+		return nil, fmt.Errorf("page %d of %s: %w", p.page, p.uri, err)
+	}
+
+	items, cursor, err := p.parse(resp.Body())
+	if err != nil {
+		p.done = true
+		return nil, fmt.Errorf("page %d of %s: %w", p.page, p.uri, err)
+	}
+
+	p.page++
+	p.offset += len(items)
+	p.cursor = cursor
+	if len(items) == 0 || (cursor == "" && len(items) < p.pageSize) {
+		p.done = true
+	}
+	return items, nil
+}
+
+func (p *Pager[T]) parse(body []byte) ([]T, string, error) {
+	var items []T
+	if p.itemsKey == "" {
+		err := json.Unmarshal(body, &items)
+		return items, "", err
+	}
+
+	var dict map[string]json.RawMessage
+	if err := json.Unmarshal(body, &dict); err != nil {
+		return nil, "", err
+	}
+	if raw, ok := dict[p.itemsKey]; ok {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, "", err
+		}
+	}
+
+	cursor := ""
+	if raw, ok := dict[p.CursorKey]; ok {
+		json.Unmarshal(raw, &cursor) //a null or non string cursor ends the cursor mode
+	}
+	return items, cursor, nil
+}
+
+// Each calls fn for every item across all pages until fn returns false or a page fails
+func (p *Pager[T]) Each(fn func(T) bool) error {
+	for {
+		items, err := p.NextPage()
+		if err != nil {
+			return err
+		}
+		if items == nil {
+			return nil
+		}
+		for _, item := range items {
+			if !fn(item) {
+				p.done = true
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build go1.23
+
+package waiops
+
+import "iter"
+
+// All ranges over every item across all pages. A failed page is yielded once with its error and ends the iteration.
+func (p *Pager[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for {
+			items, err := p.NextPage()
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if items == nil {
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					p.done = true
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+//go:build go1.23
+
+package waiops
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPagerAll(t *testing.T) {
+	requests := []string{}
+	server := cursorServer(t, 5, &requests)
+	defer server.Close()
+
+	newPager := func() *Pager[EvAlert] {
+		pager := NewPager[EvAlert](NewAPI(server.URL, "admin", "secret"), "/alerts", "items", 2)
+		pager.CursorKey, pager.CursorParam = "next", "after"
+		return pager
+	}
+
+	ids := []string{}
+	for a, err := range newPager().All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, a.Id)
+	}
+	if fmt.Sprint(ids) != "[0 1 2 3 4]" {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+
+	requests = requests[:0]
+	ids = ids[:0]
+	pager := newPager()
+	for a := range pager.All() {
+		ids = append(ids, a.Id)
+		if len(ids) == 3 {
+			break
+		}
+	}
+	if len(ids) != 3 || len(requests) != 2 {
+		t.Fatalf("expect a break on the 3rd item of the 2nd page, got %v and requests %v", ids, requests)
+	}
+	for range pager.All() {
+		t.Fatal("expect the pager to stay done after a break")
+	}
+
+	server.Close()
+	count := 0
+	for _, err := range newPager().All() {
+		count++
+		if err == nil {
+			t.Fatal("expect an error once the server is gone")
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expect the error yielded once, got %d", count)
+	}
+}
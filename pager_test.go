@@ -0,0 +1,122 @@
+package waiops
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestPagerWalksAllPages(t *testing.T) {
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+
+	for i := 0; i < 7; i++ {
+		server.SeedAlerts(NewRandomAlert())
+	}
+
+	ids := []string{}
+	err := server.API().AlertPager(3).Each(func(a EvAlert) bool {
+		ids = append(ids, a.Id)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 7 {
+		t.Fatalf("expected 7 alerts, got %d", len(ids))
+	}
+
+	count := 0
+	server.API().AlertPager(3).Each(func(a EvAlert) bool {
+		count++
+		return count < 4
+	})
+	if count != 4 {
+		t.Fatalf("expected early stop after 4 alerts, got %d", count)
+	}
+}
+
+// cursorServer serves total items, pageSize at a time, chained by an opaque cursor "c<offset>"
+func cursorServer(t *testing.T, total int, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RawQuery)
+		query := r.URL.Query()
+		if query.Has("offset") && query.Get("offset") != "0" {
+			t.Errorf("expect no offset once a cursor is returned, got %s", r.URL.RawQuery)
+		}
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		start := 0
+		if c := query.Get("after"); c != "" {
+			start, _ = strconv.Atoi(c[1:])
+		}
+
+		items := []map[string]string{}
+		for i := start; i < min(start+limit, total); i++ {
+			items = append(items, map[string]string{"id": strconv.Itoa(i)})
+		}
+		page := map[string]any{"items": items, "next": nil}
+		if start+limit < total {
+			page["next"] = fmt.Sprintf("c%d", start+limit)
+		}
+		writeMockJSON(w, http.StatusOK, page)
+	}))
+}
+
+func TestPagerCursor(t *testing.T) {
+	requests := []string{}
+	server := cursorServer(t, 5, &requests)
+	defer server.Close()
+
+	pager := NewPager[EvAlert](NewAPI(server.URL, "admin", "secret"), "/alerts", "items", 2)
+	pager.CursorKey, pager.CursorParam = "next", "after"
+
+	ids := []string{}
+	if err := pager.Each(func(a EvAlert) bool {
+		ids = append(ids, a.Id)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[0 1 2 3 4]" {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+	if len(requests) != 3 || requests[1] != "after=c2&limit=2" {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	if items, err := pager.NextPage(); items != nil || err != nil {
+		t.Fatalf("expect an exhausted pager, got %v %v", items, err)
+	}
+
+	//an early stop fetches no more pages
+	requests = requests[:0]
+	pager = NewPager[EvAlert](NewAPI(server.URL, "admin", "secret"), "/alerts", "items", 2)
+	pager.CursorKey, pager.CursorParam = "next", "after"
+	count := 0
+	pager.Each(func(a EvAlert) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 || len(requests) != 2 {
+		t.Fatalf("expect a stop on the 3rd item of the 2nd page, got %d items and requests %v", count, requests)
+	}
+	if items, _ := pager.NextPage(); items != nil {
+		t.Fatal("expect the pager to stay done after an early stop")
+	}
+}
+
+func TestPagerFailedPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMockError(w, http.StatusInternalServerError, "boom")
+	}))
+	defer server.Close()
+
+	pager := NewAPI(server.URL, "admin", "secret").AlertPager(2)
+	if err := pager.Each(func(a EvAlert) bool { return true }); err == nil {
+		t.Fatal("expect the failed page as an error")
+	}
+	if items, err := pager.NextPage(); items != nil || err != nil {
+		t.Fatal("expect the pager to be done after a failed page")
+	}
+}
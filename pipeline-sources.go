@@ -0,0 +1,236 @@
+package waiops
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// emit sends the event unless the context is done first
+func emit(ctx context.Context, out chan<- EvEvent, e EvEvent) error {
+	select {
+	case out <- e:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GeneratorSource emits Count events, or until the context is done when Count is 0, one per Interval
+type GeneratorSource struct {
+	Count    int
+	Interval time.Duration
+	Generate func() EvEvent //defaults to NewRandomEvent
+}
+
+func (s *GeneratorSource) Run(ctx context.Context, out chan<- EvEvent) error {
+	generate := s.Generate
+	if generate == nil {
+		generate = NewRandomEvent
+	}
+
+	var tick <-chan time.Time
+	if s.Interval > 0 {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for i := 0; s.Count == 0 || i < s.Count; i++ {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err := emit(ctx, out, generate()); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// FileSource reads the events of a JSON Lines file, e.g. written by a file sink, or of stdin for "-".
+// A line that cannot be decoded is logged, skipped and counted as an error of the source.
+type FileSource struct {
+	Path string
+	Mode DecodeMode
+
+	skipped atomic.Int64
+}
+
+// Skipped is the number of lines that could not be decoded
+func (s *FileSource) Skipped() int64 {
+	return s.skipped.Load()
+}
+
+func (s *FileSource) Run(ctx context.Context, out chan<- EvEvent) error {
+	var r io.Reader = os.Stdin
+	if s.Path != "-" {
+		f, err := os.Open(s.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MaxPayloadBytes*2)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e EvEvent
+		if err := Unmarshal(scanner.Bytes(), &e, s.Mode); err != nil {
+			log.Printf("Skipped %s:%d: %v", s.Path, line, err)
+			s.skipped.Add(1)
+			continue
+		}
+		if err := emit(ctx, out, e); err != nil {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// KafkaSource consumes the events of a topic. The client decides where to start from and,
+// when created with a consumer group, commits the offsets. A record that cannot be decoded is logged, skipped
+// and counted as an error of the source.
+type KafkaSource struct {
+	Client *kgo.Client
+	Topic  string //added to the topics consumed by the client when set
+
+	skipped atomic.Int64
+}
+
+// Skipped is the number of records that could not be decoded
+func (s *KafkaSource) Skipped() int64 {
+	return s.skipped.Load()
+}
+
+func (s *KafkaSource) Run(ctx context.Context, out chan<- EvEvent) error {
+	if s.Topic != "" {
+		s.Client.AddConsumeTopics(s.Topic)
+	}
+	for {
+		fetches := s.Client.PollFetches(ctx)
+		if ctx.Err() != nil || fetches.IsClientClosed() {
+			return nil
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return fmt.Errorf("failed to fetch %s[%d]: %w", errs[0].Topic, errs[0].Partition, errs[0].Err)
+		}
+		for iter := fetches.RecordIter(); !iter.Done(); {
+			r := iter.Next()
+			var e EvEvent
+			if err := json.Unmarshal(r.Value, &e); err != nil {
+				log.Printf("Skipped %s[%d]@%d: %v", r.Topic, r.Partition, r.Offset, err)
+				s.skipped.Add(1)
+				continue
+			}
+			if err := emit(ctx, out, e); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// HTTPSource receives the events POSTed as one JSON object or an array. The response waits for the events
+// to be queued, so a busy pipeline slows down the senders, and is 503 once the source stopped or 413 for a body
+// over 16 times MaxPayloadBytes.
+// A 202 tells the whole batch is queued, even when the pipeline stops meanwhile, as it drains what is queued.
+// A sender disconnecting before the response may have part of its batch queued, the delivery is at least once.
+type HTTPSource struct {
+	Addr string //listened by Run, leave empty to mount the source on another server as a handler
+
+	mu  sync.RWMutex
+	out chan<- EvEvent
+}
+
+func (s *HTTPSource) Run(ctx context.Context, out chan<- EvEvent) error {
+	s.mu.Lock()
+	s.out = out
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.out = nil
+		s.mu.Unlock()
+	}()
+
+	if s.Addr == "" {
+		<-ctx.Done()
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: s}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *HTTPSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(16*MaxPayloadBytes)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("payload exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events := []EvEvent{}
+	if err := json.Unmarshal(body, &events); err != nil {
+		var e EvEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			http.Error(w, "expect an event or an array of events: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		events = []EvEvent{e}
+	}
+
+	//the read lock keeps Run from returning while the events are queued, the pipeline keeps reading until it does
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.out == nil {
+		http.Error(w, "source not running", http.StatusServiceUnavailable)
+		return
+	}
+	for _, e := range events {
+		select {
+		case s.out <- e:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
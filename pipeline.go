@@ -0,0 +1,305 @@
+package waiops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Source emits events into the channel until it is exhausted, returning nil, or the context is done.
+// A send on the channel blocks while the next stage is busy, that is the backpressure.
+type Source interface {
+	Run(ctx context.Context, out chan<- EvEvent) error
+}
+
+// Processor transforms an event, false drops it. An error drops it too and is counted.
+type Processor interface {
+	Process(ctx context.Context, e EvEvent) (EvEvent, bool, error)
+}
+
+// ProcessorFunc adapts a function to a Processor
+type ProcessorFunc func(ctx context.Context, e EvEvent) (EvEvent, bool, error)
+
+func (f ProcessorFunc) Process(ctx context.Context, e EvEvent) (EvEvent, bool, error) {
+	return f(ctx, e)
+}
+
+// Filter keeps the events for which keep is true
+func Filter(keep func(EvEvent) bool) Processor {
+	return ProcessorFunc(func(ctx context.Context, e EvEvent) (EvEvent, bool, error) {
+		return e, keep(e), nil
+	})
+}
+
+// Map replaces every event by the result of fn
+func Map(fn func(EvEvent) EvEvent) Processor {
+	return ProcessorFunc(func(ctx context.Context, e EvEvent) (EvEvent, bool, error) {
+		return fn(e), true, nil
+	})
+}
+
+// Enrich updates the event in place, e.g. from a lookup, the event is dropped when fn fails
+func Enrich(fn func(ctx context.Context, e *EvEvent) error) Processor {
+	return ProcessorFunc(func(ctx context.Context, e EvEvent) (EvEvent, bool, error) {
+		if err := fn(ctx, &e); err != nil {
+			return e, false, err
+		}
+		return e, true, nil
+	})
+}
+
+// Sample keeps a random share of the events, rate between 0 and 1
+func Sample(rate float64) Processor {
+	return ProcessorFunc(func(ctx context.Context, e EvEvent) (EvEvent, bool, error) {
+		return e, rand.Float64() < rate, nil
+	})
+}
+
+// Throttle keeps at most limit events of the same key per window, the key defaults to the resource and type.
+// A limit of 1 drops the duplicates within the window. The expired keys are forgotten once per window.
+func Throttle(limit int, window time.Duration, key func(EvEvent) string) Processor {
+	if key == nil {
+		key = func(e EvEvent) string {
+			return fmt.Sprintf("%s/%s/%s/%s/%s", e.Resource.Name, e.Resource.Hostname, e.Type.Classification, e.Type.Condition, e.Type.EventType)
+		}
+	}
+
+	type counter struct {
+		start time.Time
+		count int
+	}
+	var mu sync.Mutex
+	counters := map[string]*counter{}
+	swept := time.Now()
+
+	return ProcessorFunc(func(ctx context.Context, e EvEvent) (EvEvent, bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+		if now.Sub(swept) >= window {
+			for k, c := range counters {
+				if now.Sub(c.start) >= window {
+					delete(counters, k)
+				}
+			}
+			swept = now
+		}
+
+		k := key(e)
+		c, ok := counters[k]
+		if !ok || now.Sub(c.start) >= window {
+			c = &counter{start: now}
+			counters[k] = c
+		}
+		c.count++
+		return e, c.count <= limit, nil
+	})
+}
+
+// StageMetrics counts what went through a stage. Queued is the backlog waiting in front of the stage.
+// The Errors of a source are the items it skipped, e.g. lines that could not be decoded.
+type StageMetrics struct {
+	Stage   string
+	In      int64
+	Out     int64
+	Dropped int64
+	Errors  int64
+	Queued  int
+}
+
+type stageCounters struct {
+	name                     string
+	in, out, dropped, errors atomic.Int64
+	queue                    chan EvEvent
+	skipped                  func() int64 //of the sources counting what they skip
+}
+
+func (c *stageCounters) snapshot() StageMetrics {
+	m := StageMetrics{
+		Stage:   c.name,
+		In:      c.in.Load(),
+		Out:     c.out.Load(),
+		Dropped: c.dropped.Load(),
+		Errors:  c.errors.Load(),
+	}
+	if c.queue != nil {
+		m.Queued = len(c.queue)
+	}
+	if c.skipped != nil {
+		m.Errors += c.skipped()
+	}
+	return m
+}
+
+// Pipeline moves the events of the sources through the processors, in order, into the sink.
+// Every stage runs in its own goroutine, connected by channels of BufferSize.
+type Pipeline struct {
+	Sources    []Source
+	Processors []Processor
+	Sink       Sink
+
+	BufferSize   int           //defaults to 100
+	DrainTimeout time.Duration //time given to the events in flight once the context is done, defaults to 10s
+
+	mu     sync.Mutex
+	stages []*stageCounters
+}
+
+func NewPipeline(sink Sink, sources ...Source) *Pipeline {
+	return &Pipeline{Sink: sink, Sources: sources}
+}
+
+// Process appends processors, for chaining
+func (p *Pipeline) Process(processors ...Processor) *Pipeline {
+	p.Processors = append(p.Processors, processors...)
+	return p
+}
+
+// Metrics returns the counters of the sources, the processors and the sink, in the pipeline order
+func (p *Pipeline) Metrics() []StageMetrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	metrics := make([]StageMetrics, len(p.stages))
+	for i, s := range p.stages {
+		metrics[i] = s.snapshot()
+	}
+	return metrics
+}
+
+func stageName(kind string, i int, v any) string {
+	if named, ok := v.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%s%d:%T", kind, i, v)
+}
+
+// Run returns once the sources are exhausted and every event reached the sink, or once the context is done
+// and the events in flight are drained. The errors of the sources are returned, the ones of the events only counted.
+func (p *Pipeline) Run(ctx context.Context) error {
+	if p.Sink == nil || len(p.Sources) == 0 {
+		return fmt.Errorf("a pipeline needs a sink and at least one source")
+	}
+	size := p.BufferSize
+	if size <= 0 {
+		size = 100
+	}
+	drain := p.DrainTimeout
+	if drain <= 0 {
+		drain = 10 * time.Second
+	}
+
+	//the processors and the sink carry on after ctx is done, until the drain timeout
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(drain)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancelWork()
+		case <-workCtx.Done():
+		}
+	})
+	defer stop()
+
+	stages := []*stageCounters{}
+	first := make(chan EvEvent, size)
+
+	var sourcesWg sync.WaitGroup
+	sourceErrs := make([]error, len(p.Sources))
+	for i, source := range p.Sources {
+		counters := &stageCounters{name: stageName("source", i, source)}
+		if s, ok := source.(interface{ Skipped() int64 }); ok {
+			counters.skipped = s.Skipped
+		}
+		stages = append(stages, counters)
+
+		//counts what the source emits before handing it to the first stage
+		out := make(chan EvEvent)
+		sourcesWg.Add(2)
+		go func() {
+			defer sourcesWg.Done()
+			defer close(out)
+			if err := source.Run(ctx, out); err != nil && !errors.Is(err, context.Canceled) {
+				sourceErrs[i] = fmt.Errorf("%s: %w", counters.name, err)
+			}
+		}()
+		go func() {
+			defer sourcesWg.Done()
+			for e := range out {
+				counters.out.Add(1)
+				first <- e
+			}
+		}()
+	}
+	go func() {
+		sourcesWg.Wait()
+		close(first)
+	}()
+
+	in := first
+	var wg sync.WaitGroup
+	for i, processor := range p.Processors {
+		counters := &stageCounters{name: stageName("processor", i, processor), queue: in}
+		stages = append(stages, counters)
+		out := make(chan EvEvent, size)
+
+		wg.Add(1)
+		go func(in <-chan EvEvent) {
+			defer wg.Done()
+			defer close(out)
+			for e := range in {
+				counters.in.Add(1)
+				if workCtx.Err() != nil {
+					counters.dropped.Add(1)
+					continue
+				}
+				result, keep, err := processor.Process(workCtx, e)
+				switch {
+				case err != nil:
+					counters.errors.Add(1)
+					log.Printf("%s failed on event %s: %v", counters.name, e.Id, err)
+				case !keep:
+					counters.dropped.Add(1)
+				default:
+					counters.out.Add(1)
+					out <- result
+				}
+			}
+		}(in)
+		in = out
+	}
+
+	sinkCounters := &stageCounters{name: stageName("sink", 0, p.Sink), queue: in}
+	stages = append(stages, sinkCounters)
+	p.mu.Lock()
+	p.stages = stages
+	p.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for e := range in {
+			sinkCounters.in.Add(1)
+			if workCtx.Err() != nil {
+				sinkCounters.dropped.Add(1)
+				continue
+			}
+			if err := p.Sink.SendEvent(workCtx, e); err != nil {
+				sinkCounters.errors.Add(1)
+				log.Printf("%s failed on event %s: %v", sinkCounters.name, e.Id, err)
+				continue
+			}
+			sinkCounters.out.Add(1)
+		}
+	}()
+
+	wg.Wait()
+	return errors.Join(sourceErrs...)
+}
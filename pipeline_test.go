@@ -0,0 +1,283 @@
+package waiops_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	. "github.com/zhiminwen/waiops"
+	"github.com/zhiminwen/waiops/kafkatest"
+)
+
+func TestPipeline(t *testing.T) {
+	n := 0
+	generator := &GeneratorSource{Count: 20, Generate: func() EvEvent {
+		n++
+		e := NewRandomEvent()
+		e.Severity = n%6 + 1
+		e.Resource.Name = "web01"
+		e.SetEventType("CPU", "problem", "high cpu")
+		return e
+	}}
+
+	sink := NewMemorySink()
+	pipeline := NewPipeline(sink, generator).Process(
+		Filter(func(e EvEvent) bool { return e.Severity >= 4 }),
+		Map(func(e EvEvent) EvEvent {
+			e.Summary = "[filtered] " + e.Summary
+			return e
+		}),
+		Enrich(func(ctx context.Context, e *EvEvent) error {
+			if e.Severity == 6 {
+				return errors.New("no enrichment for critical")
+			}
+			e.Resource.Cluster = "ocp1"
+			return nil
+		}),
+		Throttle(3, time.Minute, func(e EvEvent) string { return e.Resource.Name }),
+	)
+	pipeline.BufferSize = 2
+
+	if err := pipeline.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	events := sink.Events()
+	if len(events) != 3 {
+		t.Fatalf("expect 3 events after the throttle, got %d", len(events))
+	}
+	for _, e := range events {
+		if e.Resource.Cluster != "ocp1" || e.Summary[:10] != "[filtered]" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	}
+
+	metrics := pipeline.Metrics()
+	if len(metrics) != 6 {
+		t.Fatalf("expect 6 stages, got %+v", metrics)
+	}
+	want := []StageMetrics{
+		{Out: 20},
+		{In: 20, Out: 9, Dropped: 11},
+		{In: 9, Out: 9},
+		{In: 9, Out: 6, Errors: 3},
+		{In: 6, Out: 3, Dropped: 3},
+		{In: 3, Out: 3},
+	}
+	for i, m := range metrics {
+		m.Stage, m.Queued = "", 0
+		if m != want[i] {
+			t.Errorf("stage %d: expect %+v, got %+v", i, want[i], m)
+		}
+	}
+}
+
+func TestPipelineGracefulShutdown(t *testing.T) {
+	source := &HTTPSource{}
+	server := httptest.NewServer(source)
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	content := []byte{}
+	for i := 0; i < 3; i++ {
+		line, _ := json.Marshal(NewRandomEvent())
+		content = append(append(content, line...), '\n')
+	}
+	os.WriteFile(file, content, 0600)
+
+	//a slow sink, the events queued when the context is done must still reach it
+	sink := NewMemorySink()
+	slow := FanOutSink{sink, sinkDelay(20 * time.Millisecond)}
+	pipeline := NewPipeline(slow, source, &FileSource{Path: file})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- pipeline.Run(ctx) }()
+
+	events := []EvEvent{NewRandomEvent(), NewRandomEvent()}
+	body, _ := json.Marshal(events)
+	for {
+		resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusAccepted {
+			break
+		}
+		time.Sleep(10 * time.Millisecond) //until the source runs
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := len(sink.Events()); got != 5 {
+		t.Fatalf("expect the 5 events to be drained, got %d", got)
+	}
+
+	resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 once stopped, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPSourceRejectsLargeBatch(t *testing.T) {
+	source := &HTTPSource{}
+	events := []EvEvent{NewRandomEvent(), NewRandomEvent()}
+	body, _ := json.Marshal(events)
+
+	defer func(max int) { MaxPayloadBytes = max }(MaxPayloadBytes)
+	MaxPayloadBytes = len(body) / 32
+	w := httptest.NewRecorder()
+	source.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413 for a batch over the limit, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+type sinkDelay time.Duration
+
+func (d sinkDelay) SendEvent(ctx context.Context, e EvEvent) error {
+	select {
+	case <-time.After(time.Duration(d)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+func (d sinkDelay) SendAlert(ctx context.Context, a EvAlert) error       { return nil }
+func (d sinkDelay) SendMetrics(ctx context.Context, m MetricGroup) error { return nil }
+func (d sinkDelay) SendVertex(ctx context.Context, v Vertex) error       { return nil }
+func (d sinkDelay) Close() error                                         { return nil }
+
+func TestSampleAndThrottleWindow(t *testing.T) {
+	ctx := context.Background()
+	e := NewRandomEvent()
+	for i := 0; i < 10; i++ {
+		if _, keep, _ := Sample(0).Process(ctx, e); keep {
+			t.Fatal("expect a rate of 0 to drop everything")
+		}
+		if _, keep, _ := Sample(1).Process(ctx, e); !keep {
+			t.Fatal("expect a rate of 1 to keep everything")
+		}
+	}
+
+	throttle := Throttle(1, 50*time.Millisecond, nil)
+	keeps := []bool{}
+	for _, wait := range []time.Duration{0, 0, 60 * time.Millisecond, 0} {
+		time.Sleep(wait)
+		_, keep, _ := throttle.Process(ctx, e)
+		keeps = append(keeps, keep)
+	}
+	if fmt.Sprint(keeps) != "[true false true false]" {
+		t.Fatalf("expect the key to be kept again once the window expired, got %v", keeps)
+	}
+}
+
+func TestPipelineDrainTimeout(t *testing.T) {
+	//a sink that never completes, only the drain timeout ends the run
+	sink := NewMemorySink()
+	stuck := FanOutSink{sinkDelay(time.Hour), sink}
+	pipeline := NewPipeline(stuck, &GeneratorSource{Count: 5})
+	pipeline.DrainTimeout = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- pipeline.Run(ctx) }()
+	for m := pipeline.Metrics(); len(m) < 2 || m[1].In == 0; m = pipeline.Metrics() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect the run to end after the drain timeout")
+	}
+	m := pipeline.Metrics()[1]
+	if m.In == 0 || m.Out != 0 || m.Errors+m.Dropped != m.In {
+		t.Fatalf("expect the events in flight to fail or be dropped, got %+v", m)
+	}
+}
+
+func TestFileSourceSkipsInvalidLines(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	line, _ := json.Marshal(NewRandomEvent())
+	os.WriteFile(file, append(append(line, "\n{not json\n"...), append(line, '\n')...), 0600)
+
+	sink := NewMemorySink()
+	pipeline := NewPipeline(sink, &FileSource{Path: file})
+	if err := pipeline.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.Events()) != 2 {
+		t.Fatalf("expect the events around the invalid line, got %d", len(sink.Events()))
+	}
+	if m := pipeline.Metrics()[0]; m.Out != 2 || m.Errors != 1 {
+		t.Fatalf("expect the invalid line counted, got %+v", m)
+	}
+}
+
+func TestKafkaSource(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	client, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	event := NewRandomEvent()
+	records := []*kgo.Record{
+		{Topic: TopicLifecycleInputEvents, Value: event.AsJson()},
+		{Topic: TopicLifecycleInputEvents, Value: []byte("not json")},
+		{Topic: TopicLifecycleInputEvents, Value: event.AsJson()},
+	}
+	if err := client.ProduceSync(context.Background(), records...).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	sink := NewMemorySink()
+	pipeline := NewPipeline(sink, &KafkaSource{Client: consumer, Topic: TopicLifecycleInputEvents})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- pipeline.Run(ctx) }()
+	for len(sink.Events()) < 2 && ctx.Err() == nil {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if events := sink.Events(); len(events) != 2 || events[0].Id != event.Id {
+		t.Fatalf("expect the 2 valid events, got %d", len(events))
+	}
+	if m := pipeline.Metrics()[0]; m.Out != 2 || m.Errors != 1 {
+		t.Fatalf("expect the invalid record counted, got %+v", m)
+	}
+}
@@ -0,0 +1,152 @@
+package waiops
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/paulmach/orb/geojson"
+)
+
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemaTypes are the models with a published schema, keyed by the name used as the schema title
+var SchemaTypes = map[string]any{
+	"EvEvent":     EvEvent{},
+	"EvAlert":     EvAlert{},
+	"EvResource":  EvResource{},
+	"Incident":    Incident{},
+	"MetricGroup": MetricGroup{},
+	"Vertex":      Vertex{},
+}
+
+// constraints enforced by Validate, repeated in the schema
+var schemaOverrides = map[string]map[string]any{
+	"EvEvent.severity": {"minimum": MinSeverity, "maximum": MaxSeverity},
+	"EvAlert.severity": {"minimum": MinSeverity, "maximum": MaxSeverity},
+	"EvType.eventType": {"enum": EventTypes},
+	"EvAlert.state":    {"enum": []string{"open", "clear", "closed"}},
+	"EvResource.port":  {"type": []string{"integer", "string"}, "pattern": "^[0-9]+$", "minimum": 0, "maximum": 65535}, //a numeric string is decoded too
+	"EvLink.url":       {"format": "uri"},
+
+	"EvEvent.summary":          {"minLength": 1},
+	"EvAlert.summary":          {"minLength": 1},
+	"EvAlert.deduplicationKey": {"minLength": 1},
+	"EvType.classification":    {"minLength": 1},
+}
+
+// the fields Validate rejects when missing, kept in sync by TestJSONSchemaRequiredMatchesValidate
+var schemaRequired = map[string][]string{
+	"EvEvent": {"summary", "severity", "resource", "type"},
+	"EvAlert": {"summary", "severity", "resource", "type", "deduplicationKey", "state"},
+	"EvType":  {"classification", "eventType"},
+	"EvLink":  {"url"},
+}
+
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+// JSONSchema derives the schema document of the value's type from its Go definition
+func JSONSchema(v any) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("cannot derive a schema from nil")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	g := &schemaGenerator{defs: map[string]any{}}
+	root := g.inline(t)
+	delete(g.defs, t.Name()) //the root is inlined, not referenced
+
+	root["$schema"] = schemaDialect
+	root["$id"] = "https://github.com/zhiminwen/waiops/schemas/" + t.Name() + ".json"
+	root["title"] = t.Name()
+	if len(g.defs) > 0 {
+		root["$defs"] = g.defs
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(EvTime{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(geojson.Feature{}):
+		return map[string]any{"type": "object", "description": "GeoJSON Feature, RFC 7946"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil //placeholder against recursive types
+			g.defs[t.Name()] = g.inline(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]any{} //interface values accept anything
+}
+
+func (g *schemaGenerator) inline(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	open := false
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			// EvResource flattens its untagged Extras into the object, it stays open to any key
+			if t == reflect.TypeOf(EvResource{}) && field.Name == "Extras" {
+				open = true
+				continue
+			}
+			name = field.Name
+		}
+
+		prop := g.schema(field.Type)
+		for k, v := range schemaOverrides[t.Name()+"."+name] {
+			prop[k] = v
+		}
+		properties[name] = prop
+	}
+
+	s := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if required, ok := schemaRequired[t.Name()]; ok {
+		s["required"] = required
+	}
+	if open {
+		s["additionalProperties"] = true
+		s["description"] = "Any extra key value pair is accepted next to the known fields"
+	}
+	return s
+}
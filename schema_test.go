@@ -0,0 +1,160 @@
+package waiops
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaOfEvent(t *testing.T) {
+	content, err := JSONSchema(EvEvent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Title      string                    `json:"title"`
+		Properties map[string]map[string]any `json:"properties"`
+		Defs       map[string]map[string]any `json:"$defs"`
+	}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.Title != "EvEvent" {
+		t.Fatalf("unexpected title %s", schema.Title)
+	}
+	if schema.Properties["resource"]["$ref"] != "#/$defs/EvResource" {
+		t.Fatalf("expected resource to reference EvResource, got %v", schema.Properties["resource"])
+	}
+	if schema.Properties["occurrenceTime"]["format"] != "date-time" {
+		t.Fatalf("expected occurrenceTime as date-time, got %v", schema.Properties["occurrenceTime"])
+	}
+	if schema.Properties["severity"]["maximum"] != float64(MaxSeverity) {
+		t.Fatalf("expected severity bounds, got %v", schema.Properties["severity"])
+	}
+
+	resource := schema.Defs["EvResource"]
+	if resource["additionalProperties"] != true {
+		t.Fatalf("expected EvResource to accept extras, got %v", resource)
+	}
+	if _, ok := resource["properties"].(map[string]any)["Extras"]; ok {
+		t.Fatal("expected Extras not to be a property")
+	}
+}
+
+func TestJSONSchemaOfAlert(t *testing.T) {
+	content, err := JSONSchema(&EvAlert{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Title      string                    `json:"title"`
+		Properties map[string]map[string]any `json:"properties"`
+		Defs       map[string]map[string]any `json:"$defs"`
+	}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.Title != "EvAlert" {
+		t.Fatalf("unexpected title %s", schema.Title)
+	}
+	for _, name := range []string{"resource", "sender"} {
+		if schema.Properties[name]["$ref"] != "#/$defs/EvResource" {
+			t.Fatalf("expected %s to reference EvResource, got %v", name, schema.Properties[name])
+		}
+	}
+	if states, _ := schema.Properties["state"]["enum"].([]any); len(states) != 3 {
+		t.Fatalf("expected the alert states, got %v", schema.Properties["state"])
+	}
+	if schema.Properties["severity"]["minimum"] != float64(MinSeverity) {
+		t.Fatalf("expected severity bounds, got %v", schema.Properties["severity"])
+	}
+	if schema.Properties["lastOccurrenceTime"]["format"] != "date-time" {
+		t.Fatalf("expected lastOccurrenceTime as date-time, got %v", schema.Properties["lastOccurrenceTime"])
+	}
+
+	//the decoder accepts the port as a number or a numeric string
+	port := schema.Defs["EvResource"]["properties"].(map[string]any)["port"].(map[string]any)
+	if types, _ := port["type"].([]any); len(types) != 2 || port["pattern"] != "^[0-9]+$" {
+		t.Fatalf("expected the port as an integer or a numeric string, got %v", port)
+	}
+}
+
+// TestJSONSchemaRequiredMatchesValidate drops each property of a valid event and alert,
+// Validate must fail exactly for the ones the schema requires
+func TestJSONSchemaRequiredMatchesValidate(t *testing.T) {
+	check := func(name string, v any, validate func([]byte) error) {
+		valid, _ := json.Marshal(v)
+		var doc map[string]any
+		json.Unmarshal(valid, &doc)
+
+		typeDoc := doc["type"].(map[string]any)
+		required := map[string]bool{}
+		for _, field := range schemaRequired[name] {
+			required[field] = true
+		}
+		for _, field := range schemaRequired["EvType"] {
+			required["type."+field] = true
+		}
+
+		properties := []string{}
+		for k := range doc {
+			properties = append(properties, k)
+		}
+		for k := range typeDoc {
+			properties = append(properties, "type."+k)
+		}
+		for _, property := range properties {
+			partial := map[string]any{}
+			for k, v := range doc {
+				partial[k] = v
+			}
+			if field, ok := strings.CutPrefix(property, "type."); ok {
+				partialType := map[string]any{}
+				for k, v := range typeDoc {
+					partialType[k] = v
+				}
+				delete(partialType, field)
+				partial["type"] = partialType
+			} else {
+				delete(partial, property)
+			}
+
+			content, _ := json.Marshal(partial)
+			if err := validate(content); (err != nil) != required[property] {
+				t.Errorf("%s without %s: required in the schema is %v, Validate returned %v", name, property, required[property], err)
+			}
+		}
+	}
+
+	check("EvEvent", NewRandomEvent(), func(content []byte) error {
+		var e EvEvent
+		if err := json.Unmarshal(content, &e); err != nil {
+			return err
+		}
+		return e.Validate()
+	})
+	check("EvAlert", NewRandomAlert(), func(content []byte) error {
+		var a EvAlert
+		if err := json.Unmarshal(content, &a); err != nil {
+			return err
+		}
+		return a.Validate()
+	})
+
+	content, err := JSONSchema(EvEvent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Required []string                  `json:"required"`
+		Defs     map[string]map[string]any `json:"$defs"`
+	}
+	json.Unmarshal(content, &schema)
+	if len(schema.Required) != 4 || len(schema.Defs["EvType"]["required"].([]any)) != 2 {
+		t.Fatalf("expected the required fields in the schema, got %v and %v", schema.Required, schema.Defs["EvType"]["required"])
+	}
+}
@@ -0,0 +1,149 @@
+package waiops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// SecretResolver turns a credential reference into its value, so no plaintext credential sits in a config
+//
+//	env:NAME                 environment variable
+//	file:/path/to/file       content of the file, trailing newlines trimmed
+//	dir:/mounted/secret#key  the key file of a mounted secret directory
+//	k8s:namespace/name#key   key of a Kubernetes Secret, the namespace can be omitted
+//	literal:value            the value as is, for a plain value starting with one of the prefixes, e.g. literal:env:x
+//
+// Anything else is taken as the plain value.
+type SecretResolver struct {
+	Kubernetes kubernetes.Interface //created from the kubeconfig or in-cluster config when nil
+	Namespace  string               //defaults to the namespace of the kubeconfig context
+	Timeout    time.Duration        //of the Kubernetes lookup, defaults to 10s
+
+	once sync.Once
+	err  error
+}
+
+var defaultSecretResolver = &SecretResolver{}
+
+// ResolveSecret resolves the reference with the default resolver
+func ResolveSecret(ref string) (string, error) {
+	return defaultSecretResolver.Resolve(ref)
+}
+
+func (r *SecretResolver) Resolve(ref string) (string, error) {
+	scheme, rest, ok := strings.Cut(ref, ":")
+	if !ok {
+		return ref, nil
+	}
+
+	switch scheme {
+	case "literal":
+		return rest, nil
+
+	case "env":
+		v, ok := os.LookupEnv(rest)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", rest)
+		}
+		return v, nil
+
+	case "file":
+		return readSecretFile(rest)
+
+	case "dir":
+		dir, key, ok := strings.Cut(rest, "#")
+		if !ok || key == "" {
+			return "", fmt.Errorf("expect dir:/path#key, got %s", ref)
+		}
+		return readSecretFile(filepath.Join(dir, key))
+
+	case "k8s":
+		return r.kubernetesSecret(rest)
+	}
+
+	return ref, nil //e.g. a password containing a colon
+}
+
+func readSecretFile(file string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func (r *SecretResolver) kubernetesSecret(ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" {
+		return "", fmt.Errorf("expect k8s:namespace/name#key, got k8s:%s", ref)
+	}
+	namespace, name, ok := strings.Cut(path, "/")
+	if !ok {
+		namespace, name = "", path
+	}
+
+	if err := r.init(); err != nil {
+		return "", err
+	}
+	if namespace == "" {
+		namespace = r.Namespace
+	}
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	secret, err := r.Kubernetes.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret %s/%s", key, namespace, name)
+	}
+	return string(value), nil
+}
+
+func (r *SecretResolver) init() error {
+	r.once.Do(func() {
+		if r.Kubernetes != nil && r.Namespace != "" {
+			return
+		}
+
+		clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(),
+			&clientcmd.ConfigOverrides{},
+		)
+		if r.Namespace == "" {
+			namespace, _, err := clientConfig.Namespace()
+			if err != nil {
+				r.err = err
+				return
+			}
+			r.Namespace = namespace
+		}
+		if r.Kubernetes != nil {
+			return
+		}
+
+		restConfig, err := clientConfig.ClientConfig()
+		if err != nil {
+			r.err = fmt.Errorf("failed to load kubeconfig: %w", err)
+			return
+		}
+		r.Kubernetes, r.err = kubernetes.NewForConfig(restConfig)
+	})
+	return r.err
+}
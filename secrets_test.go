@@ -0,0 +1,50 @@
+package waiops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretResolver(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "password"), []byte("from-dir\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WAIOPS_TEST_SECRET", "from-env")
+
+	resolver := &SecretResolver{
+		Namespace: "cp4aiops",
+		Kubernetes: fake.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "cp4aiops", Name: "aiops-api"},
+			Data:       map[string][]byte{"key": []byte("from-k8s")},
+		}),
+	}
+
+	for ref, want := range map[string]string{
+		"plain":                                  "plain",
+		"env:WAIOPS_TEST_SECRET":                 "from-env",
+		"dir:" + dir + "#password":               "from-dir",
+		"file:" + filepath.Join(dir, "password"): "from-dir",
+		"k8s:aiops-api#key":                      "from-k8s",
+		"k8s:cp4aiops/aiops-api#key":             "from-k8s",
+		"literal:env:WAIOPS_TEST_SECRET":         "env:WAIOPS_TEST_SECRET",
+		"literal:literal:x":                      "literal:x",
+	} {
+		got, err := resolver.Resolve(ref)
+		if err != nil {
+			t.Fatalf("%s: %v", ref, err)
+		}
+		if got != want {
+			t.Fatalf("%s: expected %q, got %q", ref, want, got)
+		}
+	}
+
+	if _, err := resolver.Resolve("k8s:aiops-api#missing"); err == nil {
+		t.Fatal("expected an error for a missing key")
+	}
+}
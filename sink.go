@@ -0,0 +1,408 @@
+package waiops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// EventSink receives the events produced by the receivers and converters
+type EventSink interface {
+	SendEvent(ctx context.Context, event EvEvent) error
+}
+
+// EventSinkFunc adapts a function to an EventSink
+type EventSinkFunc func(ctx context.Context, event EvEvent) error
+
+func (f EventSinkFunc) SendEvent(ctx context.Context, event EvEvent) error {
+	return f(ctx, event)
+}
+
+// Sink delivers every model to one destination, so the producers do not depend on the transport
+type Sink interface {
+	EventSink
+	SendAlert(ctx context.Context, alert EvAlert) error
+	SendMetrics(ctx context.Context, metrics MetricGroup) error
+	SendVertex(ctx context.Context, vertex Vertex) error
+	Close() error
+}
+
+// Send dispatches an EvEvent, EvAlert, MetricGroup or Vertex, or a pointer to one, to the matching method of the sink
+func Send(ctx context.Context, sink Sink, v any) error {
+	switch m := v.(type) {
+	case EvEvent:
+		return sink.SendEvent(ctx, m)
+	case *EvEvent:
+		return sink.SendEvent(ctx, *m)
+	case EvAlert:
+		return sink.SendAlert(ctx, m)
+	case *EvAlert:
+		return sink.SendAlert(ctx, *m)
+	case MetricGroup:
+		return sink.SendMetrics(ctx, m)
+	case *MetricGroup:
+		return sink.SendMetrics(ctx, *m)
+	case Vertex:
+		return sink.SendVertex(ctx, m)
+	case *Vertex:
+		return sink.SendVertex(ctx, *m)
+	}
+	return fmt.Errorf("unsupported sink item %T", v)
+}
+
+// SendEvent posts the event to the events endpoint, so the API can be used as an EventSink
+func (a *API) SendEvent(ctx context.Context, event EvEvent) error {
+	_, err := a.NewRequest("POST", EventsUri).SetContext(ctx).SetBody(event).Do()
+	return err
+}
+
+// SendAlert posts the alert to the alerts endpoint
+func (a *API) SendAlert(ctx context.Context, alert EvAlert) error {
+	_, err := a.NewRequest("POST", AlertsUri).SetContext(ctx).SetBody(alert).Do()
+	return err
+}
+
+// SendMetrics posts the metric group to the metrics endpoint
+func (a *API) SendMetrics(ctx context.Context, metrics MetricGroup) error {
+	_, err := a.NewRequest("POST", MetricsUri).SetContext(ctx).SetBody(metrics).Do()
+	return err
+}
+
+// SendVertex posts the vertex to the topology resources of the REST observer
+func (a *API) SendVertex(ctx context.Context, vertex Vertex) error {
+	_, err := a.NewRequest("POST", TopologyResourcesUri).SetContext(ctx).SetBody(vertex).Do()
+	return err
+}
+
+// RESTSink posts to the API endpoints. The events and alerts are validated first unless SkipValidation is set,
+// the platform rejects the invalid ones without telling why.
+type RESTSink struct {
+	*API
+	SkipValidation bool
+}
+
+func NewRESTSink(api *API) *RESTSink {
+	return &RESTSink{API: api}
+}
+
+func (s *RESTSink) SendEvent(ctx context.Context, event EvEvent) error {
+	if !s.SkipValidation {
+		if err := event.Validate(); err != nil {
+			return fmt.Errorf("invalid event %s: %w", event.Id, err)
+		}
+	}
+	return s.API.SendEvent(ctx, event)
+}
+
+func (s *RESTSink) SendAlert(ctx context.Context, alert EvAlert) error {
+	if !s.SkipValidation {
+		if err := alert.Validate(); err != nil {
+			return fmt.Errorf("invalid alert %s: %w", alert.Id, err)
+		}
+	}
+	return s.API.SendAlert(ctx, alert)
+}
+
+func (s *RESTSink) Close() error {
+	return nil
+}
+
+// KafkaSink produces the JSON models keyed by their id. A kind without a topic is rejected.
+type KafkaSink struct {
+	Client        *kgo.Client
+	EventsTopic   string
+	AlertsTopic   string
+	MetricsTopic  string
+	TopologyTopic string
+
+	ownsClient bool
+}
+
+// NewKafkaSink produces the events into the lifecycle input topic and the alerts into the replay topic.
+// The client stays open on Close, it belongs to the caller.
+func NewKafkaSink(client *kgo.Client) *KafkaSink {
+	return &KafkaSink{
+		Client:      client,
+		EventsTopic: TopicLifecycleInputEvents,
+		AlertsTopic: TopicReplayAlerts,
+	}
+}
+
+func (s *KafkaSink) produce(ctx context.Context, kind, topic, key string, v any) error {
+	if topic == "" {
+		return fmt.Errorf("no kafka topic configured for %s", kind)
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Client.ProduceSync(ctx, &kgo.Record{Topic: topic, Key: []byte(key), Value: value}).FirstErr()
+}
+
+func (s *KafkaSink) SendEvent(ctx context.Context, event EvEvent) error {
+	return s.produce(ctx, "events", s.EventsTopic, event.Id, event)
+}
+
+func (s *KafkaSink) SendAlert(ctx context.Context, alert EvAlert) error {
+	return s.produce(ctx, "alerts", s.AlertsTopic, alert.Id, alert)
+}
+
+// SendMetrics keys the group by the resource of its first metric
+func (s *KafkaSink) SendMetrics(ctx context.Context, metrics MetricGroup) error {
+	key := ""
+	if len(metrics.Groups) > 0 {
+		key = metrics.Groups[0].ResourceId
+	}
+	return s.produce(ctx, "metrics", s.MetricsTopic, key, metrics)
+}
+
+func (s *KafkaSink) SendVertex(ctx context.Context, vertex Vertex) error {
+	return s.produce(ctx, "topology", s.TopologyTopic, vertex.UniqueId, vertex)
+}
+
+// Close closes the client only when the sink created it, see OpenSink
+func (s *KafkaSink) Close() error {
+	if s.ownsClient {
+		s.Client.Close()
+	}
+	return nil
+}
+
+// JSONLinesSink writes every model as one JSON line
+type JSONLinesSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// NewFileSink appends to the file, created when missing
+func NewFileSink(path string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesSink{w: f, closer: f}, nil
+}
+
+func NewStdoutSink() *JSONLinesSink {
+	return NewJSONLinesSink(os.Stdout)
+}
+
+func (s *JSONLinesSink) write(v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(content, '\n'))
+	return err
+}
+
+func (s *JSONLinesSink) SendEvent(ctx context.Context, event EvEvent) error {
+	return s.write(event)
+}
+
+func (s *JSONLinesSink) SendAlert(ctx context.Context, alert EvAlert) error {
+	return s.write(alert)
+}
+
+func (s *JSONLinesSink) SendMetrics(ctx context.Context, metrics MetricGroup) error {
+	return s.write(metrics)
+}
+
+func (s *JSONLinesSink) SendVertex(ctx context.Context, vertex Vertex) error {
+	return s.write(vertex)
+}
+
+func (s *JSONLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// MemorySink keeps everything sent, for the tests and the dry runs
+type MemorySink struct {
+	mu       sync.Mutex
+	events   []EvEvent
+	alerts   []EvAlert
+	metrics  []MetricGroup
+	vertices []Vertex
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) SendEvent(ctx context.Context, event EvEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *MemorySink) SendAlert(ctx context.Context, alert EvAlert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func (s *MemorySink) SendMetrics(ctx context.Context, metrics MetricGroup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = append(s.metrics, metrics)
+	return nil
+}
+
+func (s *MemorySink) SendVertex(ctx context.Context, vertex Vertex) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vertices = append(s.vertices, vertex)
+	return nil
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+func (s *MemorySink) Events() []EvEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]EvEvent{}, s.events...)
+}
+
+func (s *MemorySink) Alerts() []EvAlert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]EvAlert{}, s.alerts...)
+}
+
+func (s *MemorySink) Metrics() []MetricGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MetricGroup{}, s.metrics...)
+}
+
+func (s *MemorySink) Vertices() []Vertex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Vertex{}, s.vertices...)
+}
+
+// FanOutSink sends to every sink, even when some of them fail, and returns all the errors joined
+type FanOutSink []Sink
+
+func (f FanOutSink) each(fn func(Sink) error) error {
+	errs := []error{}
+	for _, s := range f {
+		errs = append(errs, fn(s))
+	}
+	return errors.Join(errs...)
+}
+
+func (f FanOutSink) SendEvent(ctx context.Context, event EvEvent) error {
+	return f.each(func(s Sink) error { return s.SendEvent(ctx, event) })
+}
+
+func (f FanOutSink) SendAlert(ctx context.Context, alert EvAlert) error {
+	return f.each(func(s Sink) error { return s.SendAlert(ctx, alert) })
+}
+
+func (f FanOutSink) SendMetrics(ctx context.Context, metrics MetricGroup) error {
+	return f.each(func(s Sink) error { return s.SendMetrics(ctx, metrics) })
+}
+
+func (f FanOutSink) SendVertex(ctx context.Context, vertex Vertex) error {
+	return f.each(func(s Sink) error { return s.SendVertex(ctx, vertex) })
+}
+
+func (f FanOutSink) Close() error {
+	return f.each(func(s Sink) error { return s.Close() })
+}
+
+// OpenSink creates the sink of a destination spec, several comma separated specs give a FanOutSink
+//
+//	rest           the API of the profile
+//	kafka          the kafka of the profile, events and alerts into the default topics
+//	kafka:<topic>  every kind into the topic
+//	file:<path>    JSON Lines appended to the file
+//	stdout         JSON Lines on the standard output
+//	memory         kept in memory
+//
+// A comma only separates the specs when one of the kinds follows it, e.g. file:/tmp/a,b.jsonl is a single file.
+func (p Profile) OpenSink(spec string) (Sink, error) {
+	specs := splitSinkSpecs(spec)
+	if len(specs) > 1 {
+		sinks := FanOutSink{}
+		for _, s := range specs {
+			sink, err := p.OpenSink(s)
+			if err != nil {
+				sinks.Close()
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		}
+		return sinks, nil
+	}
+
+	kind, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	switch kind {
+	case "rest":
+		api, err := p.API()
+		if err != nil {
+			return nil, err
+		}
+		return NewRESTSink(api), nil
+	case "kafka":
+		client, err := p.KafkaClient()
+		if err != nil {
+			return nil, err
+		}
+		sink := NewKafkaSink(client)
+		sink.ownsClient = true
+		if arg != "" {
+			sink.EventsTopic, sink.AlertsTopic, sink.MetricsTopic, sink.TopologyTopic = arg, arg, arg, arg
+		}
+		return sink, nil
+	case "file":
+		if arg == "" {
+			return nil, fmt.Errorf("expect file:<path>, got %s", spec)
+		}
+		return NewFileSink(arg)
+	case "stdout":
+		return NewStdoutSink(), nil
+	case "memory":
+		return NewMemorySink(), nil
+	}
+	return nil, fmt.Errorf("unsupported sink %q, expect rest, kafka[:topic], file:<path>, stdout or memory", spec)
+}
+
+var sinkKinds = []string{"rest", "kafka", "file", "stdout", "memory"}
+
+// splitSinkSpecs joins back the parts that do not start with a sink kind, they belong to the argument before
+func splitSinkSpecs(spec string) []string {
+	specs := []string{}
+	for i, part := range strings.Split(spec, ",") {
+		kind, _, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if i > 0 && !slices.Contains(sinkKinds, kind) {
+			specs[len(specs)-1] += "," + part
+			continue
+		}
+		specs = append(specs, part)
+	}
+	return specs
+}
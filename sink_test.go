@@ -0,0 +1,138 @@
+package waiops_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/zhiminwen/waiops"
+	"github.com/zhiminwen/waiops/kafkatest"
+)
+
+type failingSink struct{ *MemorySink }
+
+func (failingSink) SendEvent(ctx context.Context, event EvEvent) error {
+	return errors.New("unavailable")
+}
+
+func sinkItems() []any {
+	event, alert := NewRandomEvent(), NewRandomAlert()
+	return []any{
+		&event,
+		alert,
+		MetricGroup{Groups: []Metric{{ResourceId: "web01", Metrics: map[string]float64{"cpu": 0.5}}}},
+		*NewVertex("web01"),
+	}
+}
+
+func TestRESTSink(t *testing.T) {
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+
+	sink := NewRESTSink(server.API())
+	for _, item := range sinkItems() {
+		if err := Send(context.Background(), sink, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(server.Events()) != 1 || len(server.Alerts()) != 1 || len(server.Metrics()) != 1 || len(server.Vertices()) != 1 {
+		t.Fatalf("expect one of each, got %d events, %d alerts, %d metrics, %d vertices",
+			len(server.Events()), len(server.Alerts()), len(server.Metrics()), len(server.Vertices()))
+	}
+	if err := Send(context.Background(), sink, "text"); err == nil {
+		t.Fatal("expect an error for an unsupported item")
+	}
+}
+
+func TestRESTSinkValidates(t *testing.T) {
+	server := NewMockServer("admin", "secret")
+	defer server.Close()
+
+	invalid := NewRandomAlert()
+	invalid.Severity = 9
+	sink := NewRESTSink(server.API())
+	err := sink.SendAlert(context.Background(), invalid)
+	if violations := Violations(err); len(violations) != 1 || len(server.Alerts()) != 0 {
+		t.Fatalf("expect the alert rejected before posting, got %v", err)
+	}
+
+	sink.SkipValidation = true
+	if err := sink.SendAlert(context.Background(), invalid); err != nil || len(server.Alerts()) != 1 {
+		t.Fatalf("expect the alert posted without validation, got %v", err)
+	}
+}
+
+func TestKafkaSink(t *testing.T) {
+	kafka := kafkatest.MustFakeKafka(t, nil)
+	client, err := kafka.Client("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	sink := NewKafkaSink(client)
+	event := NewRandomEvent()
+	if err := sink.SendEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.SendVertex(context.Background(), *NewVertex("web01")); err == nil || !strings.Contains(err.Error(), "topology") {
+		t.Fatalf("expect an error for the missing topology topic, got %v", err)
+	}
+
+	records := kafka.ExpectRecords(t, TopicLifecycleInputEvents, 1)
+	if string(records[0].Key) != event.Id {
+		t.Fatalf("expect the event id as key, got %s", records[0].Key)
+	}
+}
+
+func TestFileAndFanOutSinks(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out,1.jsonl") //the comma is part of the path
+	profile := Profile{}
+	sink, err := profile.OpenSink("file:" + file + ", memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.(FanOutSink)) != 2 {
+		t.Fatalf("expect a file and a memory sink, got %d sinks", len(sink.(FanOutSink)))
+	}
+	fanOut := append(sink.(FanOutSink), failingSink{NewMemorySink()})
+
+	for _, item := range sinkItems() {
+		err := Send(context.Background(), fanOut, item)
+		if _, isEvent := item.(*EvEvent); isEvent != (err != nil) {
+			t.Fatalf("expect an error for the event only, got %v for %T", err, item)
+		}
+	}
+	if err := fanOut.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	memory := fanOut[1].(*MemorySink)
+	if len(memory.Events()) != 1 || len(memory.Alerts()) != 1 || len(memory.Metrics()) != 1 || len(memory.Vertices()) != 1 {
+		t.Fatal("expect the memory sink to get every item despite the failing one")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+		if !json.Valid(scanner.Bytes()) {
+			t.Fatalf("invalid line %s", scanner.Text())
+		}
+	}
+	if lines != 4 {
+		t.Fatalf("expect 4 lines, got %d", lines)
+	}
+
+	if _, err := profile.OpenSink("ftp:x"); err == nil {
+		t.Fatal("expect an error for an unsupported sink")
+	}
+}
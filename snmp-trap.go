@@ -0,0 +1,413 @@
+package waiops
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	oidSnmpTrapOID   = "1.3.6.1.6.3.1.1.4.1.0"
+	oidStandardTraps = "1.3.6.1.6.3.1.1.5"
+	oidSysUpTime     = "1.3.6.1.2.1.1.3.0"
+
+	oidUsmStatsUnknownEngineIDs = "1.3.6.1.6.3.15.1.1.4.0"
+)
+
+// MIBMapping resolves numeric OIDs to their MIB names, keyed by the OID without the leading dot
+type MIBMapping map[string]string
+
+// DefaultMIB covers the standard traps and the interface objects they carry
+var DefaultMIB = MIBMapping{
+	"1.3.6.1.2.1.1.3":          "sysUpTime",
+	"1.3.6.1.6.3.1.1.4.1":      "snmpTrapOID",
+	"1.3.6.1.6.3.1.1.4.3":      "snmpTrapEnterprise",
+	"1.3.6.1.6.3.1.1.5.1":      "coldStart",
+	"1.3.6.1.6.3.1.1.5.2":      "warmStart",
+	"1.3.6.1.6.3.1.1.5.3":      "linkDown",
+	"1.3.6.1.6.3.1.1.5.4":      "linkUp",
+	"1.3.6.1.6.3.1.1.5.5":      "authenticationFailure",
+	"1.3.6.1.6.3.1.1.5.6":      "egpNeighborLoss",
+	"1.3.6.1.2.1.2.2.1.1":      "ifIndex",
+	"1.3.6.1.2.1.2.2.1.2":      "ifDescr",
+	"1.3.6.1.2.1.2.2.1.7":      "ifAdminStatus",
+	"1.3.6.1.2.1.2.2.1.8":      "ifOperStatus",
+	"1.3.6.1.2.1.31.1.1.1.1":   "ifName",
+	"1.3.6.1.2.1.31.1.1.1.18":  "ifAlias",
+	"1.3.6.1.4.1.9.9.41.2.0.1": "clogMessageGenerated",
+}
+
+// LoadMIBMapping reads "name OID" pairs, one per line, as printed by snmptranslate -Tz.
+// Quotes around the fields are optional, lines starting with # are ignored. The DefaultMIB entries are included.
+func LoadMIBMapping(file string) (MIBMapping, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := MIBMapping{}
+	for k, v := range DefaultMIB {
+		m[k] = v
+	}
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expect name and OID, got %q", file, line, text)
+		}
+		name := strings.Trim(fields[0], `"`)
+		oid := strings.Trim(strings.Trim(fields[1], `"`), ".")
+		m[oid] = name
+	}
+	return m, scanner.Err()
+}
+
+// Resolve returns the name of the longest known prefix followed by the remaining index,
+// e.g. ifDescr.2 for 1.3.6.1.2.1.2.2.1.2.2. Unknown OIDs are returned as is.
+func (m MIBMapping) Resolve(oid string) string {
+	oid = strings.TrimPrefix(oid, ".")
+	for prefix := oid; prefix != ""; {
+		if name, ok := m[prefix]; ok {
+			return name + strings.TrimPrefix(oid, prefix)
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return oid
+}
+
+// SNMPTrapCondition is how a trap, by its resolved name, is turned into an event
+type SNMPTrapCondition struct {
+	Classification string
+	EventType      string
+	Severity       int
+}
+
+// SNMPTrapReceiver listens for the traps of the Params version and sends them as events to the Sink.
+// The v1/v2c traps must carry the Params community, the v3 ones at least the Params security level.
+type SNMPTrapReceiver struct {
+	Sink       EventSink
+	Params     *gosnmp.GoSNMP //community or USM parameters used to decode the traps
+	MIB        MIBMapping
+	Conditions map[string]SNMPTrapCondition //by trap name, others are problems of severity 3
+
+	listening        chan bool
+	unknownEngineIDs atomic.Uint32
+}
+
+func NewSNMPTrapReceiver(sink EventSink, params *gosnmp.GoSNMP) *SNMPTrapReceiver {
+	return &SNMPTrapReceiver{
+		Sink:      sink,
+		Params:    params,
+		MIB:       DefaultMIB,
+		listening: make(chan bool, 1),
+		Conditions: map[string]SNMPTrapCondition{
+			"linkDown":              {Classification: "Link status", EventType: "problem", Severity: 5},
+			"linkUp":                {Classification: "Link status", EventType: "resolution", Severity: 2},
+			"coldStart":             {Classification: "Device restart", EventType: "problem", Severity: 4},
+			"warmStart":             {Classification: "Device restart", EventType: "problem", Severity: 3},
+			"authenticationFailure": {Classification: "Security", EventType: "problem", Severity: 4},
+		},
+	}
+}
+
+// SNMPv2cParams decodes the traps sent with the community
+func SNMPv2cParams(community string) *gosnmp.GoSNMP {
+	return &gosnmp.GoSNMP{
+		Version:   gosnmp.Version2c,
+		Community: community,
+		Timeout:   5 * time.Second,
+	}
+}
+
+// SNMPv1Params decodes the v1 traps sent with the community
+func SNMPv1Params(community string) *gosnmp.GoSNMP {
+	params := SNMPv2cParams(community)
+	params.Version = gosnmp.Version1
+	return params
+}
+
+// SNMPv3Params decodes the traps of a USM user with authentication and privacy.
+// The receiver gets a random engine id, the v3 informs are sent to it.
+func SNMPv3Params(user string, authProtocol gosnmp.SnmpV3AuthProtocol, authPass string, privProtocol gosnmp.SnmpV3PrivProtocol, privPass string) *gosnmp.GoSNMP {
+	flags := gosnmp.AuthPriv
+	switch {
+	case authProtocol == gosnmp.NoAuth:
+		flags = gosnmp.NoAuthNoPriv
+	case privProtocol == gosnmp.NoPriv:
+		flags = gosnmp.AuthNoPriv
+	}
+	return &gosnmp.GoSNMP{
+		Version:       gosnmp.Version3,
+		SecurityModel: gosnmp.UserSecurityModel,
+		MsgFlags:      flags,
+		SecurityParameters: &gosnmp.UsmSecurityParameters{
+			AuthoritativeEngineID:    newSNMPEngineID(),
+			UserName:                 user,
+			AuthenticationProtocol:   authProtocol,
+			AuthenticationPassphrase: authPass,
+			PrivacyProtocol:          privProtocol,
+			PrivacyPassphrase:        privPass,
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+// newSNMPEngineID builds a random engine id of the net-snmp enterprise, RFC 3411 format 128
+func newSNMPEngineID() string {
+	id := make([]byte, 13)
+	copy(id, []byte{0x80, 0x00, 0x1f, 0x88, 0x80})
+	rand.Read(id[5:])
+	return string(id)
+}
+
+// Listen serves the UDP address, e.g. ":162", until the context is done
+func (r *SNMPTrapReceiver) Listen(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return r.Serve(ctx, conn)
+}
+
+// Listening receives once Listen or Serve is ready, for receivers created by NewSNMPTrapReceiver
+func (r *SNMPTrapReceiver) Listening() <-chan bool {
+	return r.listening
+}
+
+// Serve decodes one trap per datagram until the context is done. The informs are acknowledged,
+// and a v3 sender probing for the engine id gets the report of RFC 3414 3.2.3b.
+func (r *SNMPTrapReceiver) Serve(ctx context.Context, conn net.PacketConn) error {
+	defer conn.Close()
+	if r.Params.Version == gosnmp.Version3 {
+		if own, ok := r.Params.SecurityParameters.(*gosnmp.UsmSecurityParameters); !ok || own.AuthoritativeEngineID == "" {
+			return fmt.Errorf("the v3 receiver requires the AuthoritativeEngineID of its USM parameters")
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	select {
+	case r.listening <- true:
+	default:
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var from net.IP
+		if udpAddr, ok := addr.(*net.UDPAddr); ok {
+			from = udpAddr.IP
+		}
+
+		packet, err := r.Params.UnmarshalTrap(bytes.Clone(buf[:n]), false)
+		if err != nil {
+			log.Printf("Invalid trap from %s: %v", from, err)
+			continue
+		}
+		if err := r.checkOrigin(packet); err != nil {
+			log.Printf("Dropped trap from %s: %v", from, err)
+			continue
+		}
+		if r.probesEngineID(packet) {
+			r.reply(conn, addr, r.engineIDReport(packet))
+			continue
+		}
+		if err := r.checkSecurityLevel(packet); err != nil {
+			log.Printf("Dropped trap from %s: %v", from, err)
+			continue
+		}
+
+		if err := r.Sink.SendEvent(ctx, r.Convert(packet, from)); err != nil {
+			log.Printf("Failed to forward trap from %s: %v", from, err)
+		}
+		if packet.PDUType == gosnmp.InformRequest {
+			packet.PDUType = gosnmp.GetResponse
+			packet.Error, packet.ErrorIndex = gosnmp.NoError, 0
+			r.reply(conn, addr, packet)
+		}
+	}
+}
+
+// checkOrigin rejects the other versions and, for v1/v2c, the other communities, UnmarshalTrap checks neither
+func (r *SNMPTrapReceiver) checkOrigin(packet *gosnmp.SnmpPacket) error {
+	if packet.Version != r.Params.Version {
+		return fmt.Errorf("version %s, expect %s", packet.Version, r.Params.Version)
+	}
+	if packet.Version != gosnmp.Version3 && packet.Community != r.Params.Community {
+		return fmt.Errorf("wrong community")
+	}
+	return nil
+}
+
+// checkSecurityLevel rejects the v3 traps with less authentication or privacy than the Params,
+// UnmarshalTrap only checks the ones sent with authentication
+func (r *SNMPTrapReceiver) checkSecurityLevel(packet *gosnmp.SnmpPacket) error {
+	if packet.Version != gosnmp.Version3 {
+		return nil
+	}
+	if packet.MsgFlags&gosnmp.AuthPriv < r.Params.MsgFlags&gosnmp.AuthPriv {
+		return fmt.Errorf("security level %d below %d", packet.MsgFlags&gosnmp.AuthPriv, r.Params.MsgFlags&gosnmp.AuthPriv)
+	}
+	return nil
+}
+
+// probesEngineID tells a v3 message sent with an invalid engine id, RFC 3411 section 5 expects 5 to 32 bytes
+func (r *SNMPTrapReceiver) probesEngineID(packet *gosnmp.SnmpPacket) bool {
+	if packet.Version != gosnmp.Version3 || r.Params.SecurityModel != gosnmp.UserSecurityModel {
+		return false
+	}
+	params, ok := packet.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if !ok {
+		return false
+	}
+	return len(params.AuthoritativeEngineID) < 5 || len(params.AuthoritativeEngineID) > 32
+}
+
+func (r *SNMPTrapReceiver) engineIDReport(packet *gosnmp.SnmpPacket) *gosnmp.SnmpPacket {
+	params := packet.SecurityParameters.Copy().(*gosnmp.UsmSecurityParameters)
+	if own, ok := r.Params.SecurityParameters.(*gosnmp.UsmSecurityParameters); ok {
+		params.AuthoritativeEngineID = own.AuthoritativeEngineID
+	}
+
+	report := *packet
+	report.PDUType = gosnmp.Report
+	report.SecurityParameters = params
+	report.Variables = []gosnmp.SnmpPDU{
+		{Name: oidUsmStatsUnknownEngineIDs, Type: gosnmp.Counter32, Value: r.unknownEngineIDs.Add(1)},
+	}
+	return &report
+}
+
+func (r *SNMPTrapReceiver) reply(conn net.PacketConn, addr net.Addr, packet *gosnmp.SnmpPacket) {
+	msg, err := packet.MarshalMsg()
+	if err == nil {
+		_, err = conn.WriteTo(msg, addr)
+	}
+	if err != nil {
+		log.Printf("Failed to reply to %s: %v", addr, err)
+	}
+}
+
+// trapOID finds the OID of a v2c/v3 trap in snmpTrapOID.0, or derives it from the v1 header
+func trapOID(packet *gosnmp.SnmpPacket) string {
+	for _, v := range packet.Variables {
+		if strings.TrimPrefix(v.Name, ".") == oidSnmpTrapOID {
+			if s, ok := v.Value.(string); ok {
+				return strings.TrimPrefix(s, ".")
+			}
+		}
+	}
+
+	if packet.Version == gosnmp.Version1 {
+		if packet.GenericTrap < 6 {
+			return fmt.Sprintf("%s.%d", oidStandardTraps, packet.GenericTrap+1)
+		}
+		return fmt.Sprintf("%s.0.%d", strings.TrimPrefix(packet.Enterprise, "."), packet.SpecificTrap)
+	}
+	return ""
+}
+
+func snmpValueString(v gosnmp.SnmpPDU) string {
+	switch value := v.Value.(type) {
+	case []byte:
+		return string(value)
+	case string:
+		return strings.TrimPrefix(value, ".")
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v.Value)
+}
+
+// Convert builds the event of a trap received from the sender IP
+func (r *SNMPTrapReceiver) Convert(packet *gosnmp.SnmpPacket, from net.IP) EvEvent {
+	trapName := r.MIB.Resolve(trapOID(packet))
+
+	resource := EvResource{Extras: map[string]any{}}
+	if from != nil {
+		resource.IpAddress = from.String()
+	}
+	if packet.Version == gosnmp.Version1 && packet.AgentAddress != "" && packet.AgentAddress != "0.0.0.0" {
+		resource.IpAddress = packet.AgentAddress
+	}
+	resource.Hostname = resource.IpAddress
+	resource.Name = resource.IpAddress
+
+	details := map[string]string{"trapOID": trapOID(packet)}
+	var ifName, ifDescr string
+	for _, v := range packet.Variables {
+		oid := strings.TrimPrefix(v.Name, ".")
+		if oid == oidSnmpTrapOID || oid == oidSysUpTime {
+			continue
+		}
+		name := r.MIB.Resolve(oid)
+		value := snmpValueString(v)
+		details[name] = value
+
+		base, _, _ := strings.Cut(name, ".")
+		switch base {
+		case "ifName":
+			ifName = value
+		case "ifDescr":
+			ifDescr = value
+		case "ifIndex":
+			resource.Extras["ifIndex"] = value
+		}
+	}
+	resource.Interface = ifName
+	if resource.Interface == "" {
+		resource.Interface = ifDescr
+	}
+
+	condition, ok := r.Conditions[trapName]
+	if !ok {
+		condition = SNMPTrapCondition{Classification: "SNMP trap", EventType: "problem", Severity: 3}
+	}
+
+	summary := fmt.Sprintf("%s from %s", trapName, resource.IpAddress)
+	if resource.Interface != "" {
+		summary = fmt.Sprintf("%s on %s of %s", trapName, resource.Interface, resource.IpAddress)
+	}
+
+	return EvEvent{
+		Id:             gofakeit.UUID(),
+		OccurrenceTime: EvTime(time.Now()),
+		Summary:        summary,
+		Severity:       condition.Severity,
+		Sender:         EvResource{Name: "snmptrap", Service: "snmptrap"},
+		Resource:       resource,
+		Type: EvType{
+			Classification: condition.Classification,
+			EventType:      condition.EventType,
+			Condition:      trapName,
+		},
+		Details: details,
+	}
+}
@@ -0,0 +1,235 @@
+package waiops
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestMIBMapping(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mib.txt")
+	os.WriteFile(file, []byte("# snmptranslate -Tz\n\"ciscoEnvMonTemperatureNotification\"\t\"1.3.6.1.4.1.9.9.13.3.0.3\"\nentPhysicalName .1.3.6.1.2.1.47.1.1.1.1.7\n"), 0600)
+
+	mib, err := LoadMIBMapping(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for oid, name := range map[string]string{
+		".1.3.6.1.4.1.9.9.13.3.0.3":   "ciscoEnvMonTemperatureNotification",
+		"1.3.6.1.2.1.47.1.1.1.1.7.12": "entPhysicalName.12",
+		"1.3.6.1.2.1.2.2.1.2.3":       "ifDescr.3",
+		"1.3.6.1.4.1.99999.1":         "1.3.6.1.4.1.99999.1",
+	} {
+		if got := mib.Resolve(oid); got != name {
+			t.Errorf("Resolve(%s) = %s, want %s", oid, got, name)
+		}
+	}
+}
+
+// serveTraps runs the receiver on a local port and returns a client of it, configured by params
+func serveTraps(t *testing.T, receiver *SNMPTrapReceiver, params *gosnmp.GoSNMP) *gosnmp.GoSNMP {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go receiver.Serve(ctx, conn)
+
+	params.Target = "127.0.0.1"
+	params.Port = uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+	params.Timeout = time.Second
+	if err := params.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { params.Conn.Close() })
+	return params
+}
+
+var linkDownTrap = gosnmp.SnmpTrap{Variables: []gosnmp.SnmpPDU{
+	{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(1234)},
+	{Name: ".1.3.6.1.6.3.1.1.4.1.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.6.3.1.1.5.3"},
+	{Name: ".1.3.6.1.2.1.2.2.1.1.2", Type: gosnmp.Integer, Value: 2},
+	{Name: ".1.3.6.1.2.1.2.2.1.2.2", Type: gosnmp.OctetString, Value: "eth1"},
+	{Name: ".1.3.6.1.2.1.2.2.1.8.2", Type: gosnmp.Integer, Value: 2},
+}}
+
+func expectLinkDown(t *testing.T, events <-chan EvEvent) {
+	t.Helper()
+	select {
+	case e := <-events:
+		if e.Type.Condition != "linkDown" || e.Type.EventType != "problem" || e.Severity != 5 {
+			t.Fatalf("unexpected event type %+v, severity %d", e.Type, e.Severity)
+		}
+		if e.Resource.Interface != "eth1" || e.Resource.IpAddress != "127.0.0.1" {
+			t.Fatalf("unexpected resource %+v", e.Resource)
+		}
+		if e.Details["ifOperStatus.2"] != "2" {
+			t.Fatalf("unexpected details %v", e.Details)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+// expectNoEvent fails the test if an event arrives shortly
+func expectNoEvent(t *testing.T, events <-chan EvEvent, why string) {
+	t.Helper()
+	select {
+	case e := <-events:
+		t.Fatalf("expected no event for %s, got %+v", why, e)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSNMPTrapReceiverV2c(t *testing.T) {
+	events := make(chan EvEvent, 1)
+	receiver := NewSNMPTrapReceiver(EventSinkFunc(func(ctx context.Context, e EvEvent) error {
+		events <- e
+		return nil
+	}), SNMPv2cParams("public"))
+
+	client := serveTraps(t, receiver, &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public"})
+	if _, err := client.SendTrap(linkDownTrap); err != nil {
+		t.Fatal(err)
+	}
+	expectLinkDown(t, events)
+
+	//an inform waits for the acknowledgement
+	inform := linkDownTrap
+	inform.IsInform = true
+	if _, err := client.SendTrap(inform); err != nil {
+		t.Fatalf("expected the inform to be acknowledged: %v", err)
+	}
+	expectLinkDown(t, events)
+
+	wrongCommunity := serveTraps(t, receiver, &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "private"})
+	if _, err := wrongCommunity.SendTrap(linkDownTrap); err != nil {
+		t.Fatal(err)
+	}
+	expectNoEvent(t, events, "a wrong community")
+
+	v1 := serveTraps(t, receiver, &gosnmp.GoSNMP{Version: gosnmp.Version1, Community: "public"})
+	if _, err := v1.SendTrap(gosnmp.SnmpTrap{Variables: linkDownTrap.Variables[2:], Enterprise: ".1.3.6.1.4.1.8072", AgentAddress: "127.0.0.1", GenericTrap: 2}); err != nil {
+		t.Fatal(err)
+	}
+	expectNoEvent(t, events, "a v1 trap")
+}
+
+func TestSNMPTrapReceiverV3(t *testing.T) {
+	events := make(chan EvEvent, 1)
+	receiver := NewSNMPTrapReceiver(EventSinkFunc(func(ctx context.Context, e EvEvent) error {
+		events <- e
+		return nil
+	}), SNMPv3Params("aiops", gosnmp.SHA, "authpass123", gosnmp.AES, "privpass123"))
+
+	sender := func(privPass string) *gosnmp.GoSNMP {
+		return serveTraps(t, receiver, &gosnmp.GoSNMP{
+			Version:       gosnmp.Version3,
+			SecurityModel: gosnmp.UserSecurityModel,
+			MsgFlags:      gosnmp.AuthPriv,
+			SecurityParameters: &gosnmp.UsmSecurityParameters{
+				UserName:                 "aiops",
+				AuthoritativeEngineID:    "\x80\x00\x1f\x88\x04waiops",
+				AuthenticationProtocol:   gosnmp.SHA,
+				AuthenticationPassphrase: "authpass123",
+				PrivacyProtocol:          gosnmp.AES,
+				PrivacyPassphrase:        privPass,
+			},
+		})
+	}
+
+	if _, err := sender("privpass123").SendTrap(linkDownTrap); err != nil {
+		t.Fatal(err)
+	}
+	expectLinkDown(t, events)
+
+	//a wrong privacy passphrase cannot be decrypted
+	if _, err := sender("wrongpass123").SendTrap(linkDownTrap); err != nil {
+		t.Fatal(err)
+	}
+	expectNoEvent(t, events, "the wrong passphrase")
+
+	//neither authenticated nor encrypted
+	noAuth := serveTraps(t, receiver, &gosnmp.GoSNMP{
+		Version:       gosnmp.Version3,
+		SecurityModel: gosnmp.UserSecurityModel,
+		MsgFlags:      gosnmp.NoAuthNoPriv,
+		SecurityParameters: &gosnmp.UsmSecurityParameters{
+			UserName:              "aiops",
+			AuthoritativeEngineID: "\x80\x00\x1f\x88\x04waiops",
+		},
+	})
+	if _, err := noAuth.SendTrap(linkDownTrap); err != nil {
+		t.Fatal(err)
+	}
+	expectNoEvent(t, events, "a noAuthNoPriv trap")
+
+	v2c := serveTraps(t, receiver, &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public"})
+	if _, err := v2c.SendTrap(linkDownTrap); err != nil {
+		t.Fatal(err)
+	}
+	expectNoEvent(t, events, "a v2c trap")
+
+	//the sender of an inform discovers the engine id of the receiver first
+	informer := serveTraps(t, receiver, &gosnmp.GoSNMP{
+		Version:       gosnmp.Version3,
+		SecurityModel: gosnmp.UserSecurityModel,
+		MsgFlags:      gosnmp.AuthPriv,
+		SecurityParameters: &gosnmp.UsmSecurityParameters{
+			UserName:                 "aiops",
+			AuthenticationProtocol:   gosnmp.SHA,
+			AuthenticationPassphrase: "authpass123",
+			PrivacyProtocol:          gosnmp.AES,
+			PrivacyPassphrase:        "privpass123",
+		},
+	})
+	inform := linkDownTrap
+	inform.IsInform = true
+	if _, err := informer.SendTrap(inform); err != nil {
+		t.Fatalf("expected the inform to be acknowledged: %v", err)
+	}
+	expectLinkDown(t, events)
+	own := receiver.Params.SecurityParameters.(*gosnmp.UsmSecurityParameters).AuthoritativeEngineID
+	if got := informer.SecurityParameters.(*gosnmp.UsmSecurityParameters).AuthoritativeEngineID; len(own) < 5 || got != own {
+		t.Fatalf("expected the engine id %x discovered, got %x", own, got)
+	}
+}
+
+func TestSNMPTrapReceiverRequiresEngineID(t *testing.T) {
+	params := SNMPv3Params("aiops", gosnmp.SHA, "authpass123", gosnmp.AES, "privpass123")
+	params.SecurityParameters.(*gosnmp.UsmSecurityParameters).AuthoritativeEngineID = ""
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSNMPTrapReceiver(nil, params).Serve(context.Background(), conn); err == nil {
+		t.Fatal("expected an error for a v3 receiver without engine id")
+	}
+}
+
+func TestSNMPTrapReceiverV1(t *testing.T) {
+	receiver := NewSNMPTrapReceiver(nil, SNMPv1Params("public"))
+	e := receiver.Convert(&gosnmp.SnmpPacket{
+		Version: gosnmp.Version1,
+		SnmpTrap: gosnmp.SnmpTrap{
+			AgentAddress: "10.0.0.5",
+			GenericTrap:  3, //linkUp
+		},
+		Variables: []gosnmp.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.31.1.1.1.1.7", Type: gosnmp.OctetString, Value: []byte("eno1")},
+		},
+	}, net.ParseIP("192.168.1.1"))
+
+	if e.Type.Condition != "linkUp" || e.Type.EventType != "resolution" {
+		t.Fatalf("unexpected event type %+v", e.Type)
+	}
+	if e.Resource.Interface != "eno1" || e.Resource.IpAddress != "10.0.0.5" {
+		t.Fatalf("unexpected resource %+v", e.Resource)
+	}
+}
@@ -0,0 +1,411 @@
+package waiops
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// SyslogMessage is a parsed RFC 3164 or RFC 5424 message. Fields missing from the message are left empty.
+type SyslogMessage struct {
+	Facility       int
+	Severity       int //0 emergency to 7 debug
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData string
+	Message        string
+}
+
+// ParseSyslog parses one message, RFC 5424 when the version follows the priority, RFC 3164 otherwise
+func ParseSyslog(line []byte) (SyslogMessage, error) {
+	var msg SyslogMessage
+	s := strings.TrimRight(string(line), "\r\n\x00")
+
+	if !strings.HasPrefix(s, "<") {
+		return msg, fmt.Errorf("missing priority")
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return msg, fmt.Errorf("invalid priority")
+	}
+	pri, err := strconv.Atoi(s[1:end])
+	if err != nil || pri > 191 {
+		return msg, fmt.Errorf("invalid priority %q", s[1:end])
+	}
+	msg.Facility = pri / 8
+	msg.Severity = pri % 8
+	s = s[end+1:]
+
+	if strings.HasPrefix(s, "1 ") {
+		return parseRFC5424(msg, s[2:])
+	}
+	return parseRFC3164(msg, s), nil
+}
+
+func parseRFC5424(msg SyslogMessage, s string) (SyslogMessage, error) {
+	header := strings.SplitN(s, " ", 6)
+	if len(header) < 6 {
+		return msg, fmt.Errorf("truncated RFC 5424 header")
+	}
+	nilValue := func(v string) string {
+		if v == "-" {
+			return ""
+		}
+		return v
+	}
+
+	if header[0] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return msg, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		msg.Timestamp = ts
+	}
+	msg.Hostname = nilValue(header[1])
+	msg.AppName = nilValue(header[2])
+	msg.ProcID = nilValue(header[3])
+	msg.MsgID = nilValue(header[4])
+
+	rest := header[5]
+	if strings.HasPrefix(rest, "-") {
+		msg.Message = strings.TrimPrefix(rest[1:], " ")
+	} else {
+		sdEnd, err := structuredDataEnd(rest)
+		if err != nil {
+			return msg, err
+		}
+		msg.StructuredData = rest[:sdEnd]
+		msg.Message = strings.TrimPrefix(rest[sdEnd:], " ")
+	}
+	msg.Message = strings.TrimPrefix(msg.Message, "\ufeff") //BOM of UTF-8 messages
+	return msg, nil
+}
+
+// structuredDataEnd finds the end of the SD elements, skipping the escaped \] within the param values
+func structuredDataEnd(s string) (int, error) {
+	if !strings.HasPrefix(s, "[") {
+		return 0, fmt.Errorf("invalid structured data")
+	}
+
+	inElement, inValue := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case !inElement && c == '[':
+			inElement = true
+		case !inElement:
+			return i, nil
+		case inValue && c == '\\':
+			i++
+		case c == '"':
+			inValue = !inValue
+		case !inValue && c == ']':
+			inElement = false
+		}
+	}
+	if inElement {
+		return 0, fmt.Errorf("unterminated structured data")
+	}
+	return len(s), nil
+}
+
+var rfc3164Tag = regexp.MustCompile(`^([^\s:\[]+)(?:\[([^\]]*)\])?:\s?`)
+
+// parseRFC3164 is best effort, devices differ a lot in what they send after the priority
+func parseRFC3164(msg SyslogMessage, s string) SyslogMessage {
+	if len(s) >= 15 {
+		if ts, err := time.ParseInLocation(time.Stamp, s[:15], time.Local); err == nil {
+			msg.Timestamp = rfc3164Year(ts, time.Now())
+			s = strings.TrimPrefix(s[15:], " ")
+
+			// the hostname follows the timestamp, unless the tag comes right away
+			if host, rest, ok := strings.Cut(s, " "); ok && !strings.HasSuffix(host, ":") && !strings.Contains(host, "[") {
+				msg.Hostname = host
+				s = rest
+			}
+		}
+	}
+
+	if m := rfc3164Tag.FindStringSubmatch(s); m != nil {
+		msg.AppName = m[1]
+		msg.ProcID = m[2]
+		s = s[len(m[0]):]
+	}
+	msg.Message = s
+	return msg
+}
+
+// rfc3164Year gives the timestamp without a year the one of now, or the year before for the December messages
+// received in January. The date is rebuilt rather than shifted, a shift would move Feb 29 to Mar 1.
+func rfc3164Year(ts, now time.Time) time.Time {
+	year := now.Year()
+	if time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), ts.Location()).After(now.Add(24 * time.Hour)) {
+		year--
+	}
+	return time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), ts.Location())
+}
+
+// SyslogRule extracts the event type and summary from the messages matching the pattern.
+// The templates can refer to the named or numbered groups of the pattern, e.g. ${ifname} or $1.
+type SyslogRule struct {
+	Pattern        *regexp.Regexp
+	Classification string
+	EventType      string
+	Condition      string
+	Summary        string //the message when empty
+	Severity       int    //overrides the syslog severity when set
+}
+
+func (rule SyslogRule) expand(template string, match []int, message string) string {
+	return string(rule.Pattern.ExpandString(nil, template, message, match))
+}
+
+// SyslogReceiver listens for syslog messages and sends them as events to the Sink
+type SyslogReceiver struct {
+	Sink  EventSink
+	Rules []SyslogRule //the first matching rule applies
+
+	// SeverityFunc maps the syslog facility and severity to the 1-6 scale, DefaultSyslogSeverity if nil
+	SeverityFunc func(facility, severity int) int
+}
+
+func NewSyslogReceiver(sink EventSink, rules ...SyslogRule) *SyslogReceiver {
+	return &SyslogReceiver{
+		Sink:         sink,
+		Rules:        rules,
+		SeverityFunc: DefaultSyslogSeverity,
+	}
+}
+
+// DefaultSyslogSeverity maps emergency, alert and critical to critical, error to major,
+// warning to warning, notice and informational to information and debug to indeterminate
+func DefaultSyslogSeverity(facility, severity int) int {
+	switch severity {
+	case 0, 1, 2:
+		return 6
+	case 3:
+		return 5
+	case 4:
+		return 3
+	case 5, 6:
+		return 2
+	}
+	return 1
+}
+
+// Convert builds the event of a message received from the sender IP
+func (r *SyslogReceiver) Convert(msg SyslogMessage, from net.IP) EvEvent {
+	occurrence := msg.Timestamp
+	if occurrence.IsZero() {
+		occurrence = time.Now()
+	}
+
+	resource := EvResource{
+		Hostname: msg.Hostname,
+		Service:  msg.AppName,
+		Extras:   map[string]any{},
+	}
+	if from != nil {
+		resource.IpAddress = from.String()
+	}
+	if resource.Hostname == "" {
+		resource.Hostname = resource.IpAddress
+	}
+	resource.Name = resource.Hostname
+
+	severityFunc := r.SeverityFunc
+	if severityFunc == nil {
+		severityFunc = DefaultSyslogSeverity
+	}
+
+	event := EvEvent{
+		Id:             gofakeit.UUID(),
+		OccurrenceTime: EvTime(occurrence),
+		Summary:        msg.Message,
+		Severity:       severityFunc(msg.Facility, msg.Severity),
+		Sender:         EvResource{Name: "syslog", Service: "syslog"},
+		Resource:       resource,
+		Type: EvType{
+			Classification: "Syslog",
+			EventType:      "problem",
+			Condition:      msg.AppName,
+		},
+		Details: map[string]string{
+			"facility": strconv.Itoa(msg.Facility),
+			"severity": strconv.Itoa(msg.Severity),
+		},
+	}
+	if msg.ProcID != "" {
+		event.Details["procId"] = msg.ProcID
+	}
+	if msg.MsgID != "" {
+		event.Details["msgId"] = msg.MsgID
+	}
+	if msg.StructuredData != "" {
+		event.Details["structuredData"] = msg.StructuredData
+	}
+
+	for _, rule := range r.Rules {
+		match := rule.Pattern.FindStringSubmatchIndex(msg.Message)
+		if match == nil {
+			continue
+		}
+		if rule.Classification != "" {
+			event.Type.Classification = rule.expand(rule.Classification, match, msg.Message)
+		}
+		if rule.EventType != "" {
+			event.Type.EventType = rule.expand(rule.EventType, match, msg.Message)
+		}
+		if rule.Condition != "" {
+			event.Type.Condition = rule.expand(rule.Condition, match, msg.Message)
+		}
+		if rule.Summary != "" {
+			event.Summary = rule.expand(rule.Summary, match, msg.Message)
+		}
+		if rule.Severity != 0 {
+			event.Severity = rule.Severity
+		}
+		break
+	}
+	if event.Type.Condition == "" {
+		event.Type.Condition = "syslog message"
+	}
+	return event
+}
+
+// Handle parses and forwards one message
+func (r *SyslogReceiver) Handle(ctx context.Context, line []byte, from net.IP) error {
+	msg, err := ParseSyslog(line)
+	if err != nil {
+		return err
+	}
+	return r.Sink.SendEvent(ctx, r.Convert(msg, from))
+}
+
+func (r *SyslogReceiver) handleAndLog(ctx context.Context, line []byte, from net.IP) {
+	if err := r.Handle(ctx, line, from); err != nil {
+		log.Printf("Failed to handle syslog message from %s: %v", from, err)
+	}
+}
+
+// ListenUDP serves the address, e.g. ":514", until the context is done
+func (r *SyslogReceiver) ListenUDP(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return r.ServeUDP(ctx, conn)
+}
+
+// ServeUDP reads one message per datagram until the context is done
+func (r *SyslogReceiver) ServeUDP(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var from net.IP
+		if udpAddr, ok := addr.(*net.UDPAddr); ok {
+			from = udpAddr.IP
+		}
+		r.handleAndLog(ctx, bytes.Clone(buf[:n]), from)
+	}
+}
+
+// ListenTCP serves the address until the context is done
+func (r *SyslogReceiver) ListenTCP(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return r.ServeTCP(ctx, ln)
+}
+
+// ServeTCP accepts both the newline and the octet counting framing of RFC 6587
+func (r *SyslogReceiver) ServeTCP(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go r.serveConn(ctx, conn)
+	}
+}
+
+func (r *SyslogReceiver) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var from net.IP
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		from = tcpAddr.IP
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := readSyslogFrame(reader)
+		if len(line) > 0 {
+			r.handleAndLog(ctx, line, from)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				log.Printf("Syslog connection from %s closed: %v", from, err)
+			}
+			return
+		}
+	}
+}
+
+func readSyslogFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		lenStr, err := reader.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(lenStr))
+		if err != nil || n > 1024*1024 {
+			return nil, fmt.Errorf("invalid frame length %q", lenStr)
+		}
+		frame := make([]byte, n)
+		_, err = io.ReadFull(reader, frame)
+		return frame, err
+	}
+
+	return reader.ReadBytes('\n')
+}
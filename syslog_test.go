@@ -0,0 +1,146 @@
+package waiops
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	msg, err := ParseSyslog([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application \] x"] An application event`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Facility != 20 || msg.Severity != 5 || msg.Hostname != "mymachine.example.com" || msg.AppName != "evntslog" || msg.MsgID != "ID47" {
+		t.Fatalf("unexpected RFC 5424 message %+v", msg)
+	}
+	if msg.Message != "An application event" {
+		t.Fatalf("unexpected message %q", msg.Message)
+	}
+
+	msg, err = ParseSyslog([]byte(`<187>Oct 11 22:14:15 router1 %LINK-3-UPDOWN[42]: Interface GigabitEthernet0/1, changed state to down`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Severity != 3 || msg.Hostname != "router1" || msg.AppName != "%LINK-3-UPDOWN" || msg.ProcID != "42" {
+		t.Fatalf("unexpected RFC 3164 message %+v", msg)
+	}
+	if msg.Message != "Interface GigabitEthernet0/1, changed state to down" {
+		t.Fatalf("unexpected message %q", msg.Message)
+	}
+}
+
+func TestSyslogReceiverOverUDP(t *testing.T) {
+	events := make(chan EvEvent, 1)
+	receiver := NewSyslogReceiver(
+		EventSinkFunc(func(ctx context.Context, e EvEvent) error {
+			events <- e
+			return nil
+		}),
+		SyslogRule{
+			Pattern:        regexp.MustCompile(`Interface (?P<ifname>\S+), changed state to (?P<state>up|down)`),
+			Classification: "Link status",
+			Condition:      "link${state}",
+			Summary:        "Interface ${ifname} is ${state}",
+		},
+	)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go receiver.ServeUDP(ctx, conn)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Write([]byte(`<187>Oct 11 22:14:15 router1 %LINK-3-UPDOWN: Interface Gi0/1, changed state to down`))
+
+	select {
+	case e := <-events:
+		if e.Severity != 5 || e.Type.Condition != "linkdown" || e.Summary != "Interface Gi0/1 is down" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+		if e.Resource.Hostname != "router1" || e.Resource.IpAddress != "127.0.0.1" {
+			t.Fatalf("unexpected resource %+v", e.Resource)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestSyslogReceiverOverTCP(t *testing.T) {
+	events := make(chan EvEvent, 3)
+	receiver := NewSyslogReceiver(EventSinkFunc(func(ctx context.Context, e EvEvent) error {
+		events <- e
+		return nil
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go receiver.ServeTCP(ctx, ln)
+
+	//octet counting, a frame may hold a newline
+	first := "<187>Oct 11 22:14:15 router1 link: first\nline"
+	second := "<187>Oct 11 22:14:16 router1 link: second"
+	octets, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(octets, "%d %s%d %s", len(first), first, len(second), second)
+	octets.Close()
+
+	newline, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(newline, "<187>Oct 11 22:14:17 router2 link: third\n")
+	newline.Close()
+
+	got := map[string]string{}
+	for i := 0; i < 3; i++ {
+		select {
+		case e := <-events:
+			got[e.Summary] = e.Resource.Hostname
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected 3 events, got %v", got)
+		}
+	}
+	if got["first\nline"] != "router1" || got["second"] != "router1" || got["third"] != "router2" {
+		t.Fatalf("unexpected events %v", got)
+	}
+}
+
+func TestRFC3164Year(t *testing.T) {
+	leapDay := time.Date(0, 2, 29, 10, 0, 0, 0, time.UTC)
+	if got := rfc3164Year(leapDay, time.Date(2028, 3, 1, 0, 0, 0, 0, time.UTC)); got.Month() != 2 || got.Day() != 29 || got.Year() != 2028 {
+		t.Fatalf("expected Feb 29 2028, got %v", got)
+	}
+	if got := rfc3164Year(leapDay, time.Date(2029, 1, 10, 0, 0, 0, 0, time.UTC)); got.Month() != 2 || got.Day() != 29 || got.Year() != 2028 {
+		t.Fatalf("expected Feb 29 of the year before, got %v", got)
+	}
+
+	december := time.Date(0, 12, 31, 23, 59, 0, 0, time.UTC)
+	if got := rfc3164Year(december, time.Date(2029, 1, 1, 0, 1, 0, 0, time.UTC)); got.Year() != 2028 {
+		t.Fatalf("expected the year before, got %v", got)
+	}
+}
+
+func TestSyslogReceiverLiteral(t *testing.T) {
+	receiver := &SyslogReceiver{}
+	event := receiver.Convert(SyslogMessage{Severity: 3, Hostname: "router1", Message: "link down"}, nil)
+	if event.Severity != 5 {
+		t.Fatalf("expected the default severity mapping, got %d", event.Severity)
+	}
+}
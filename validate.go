@@ -0,0 +1,150 @@
+package waiops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+const (
+	MinSeverity = 1
+	MaxSeverity = 6
+)
+
+// The platform does not publish its expiry and payload limits, these defaults only catch the likely mistakes,
+// e.g. an expiry in milliseconds or a runaway payload. Set them to the limits of the deployment.
+var (
+	MaxExpirySeconds = 30 * 24 * 3600
+	MaxPayloadBytes  = 256 * 1024
+)
+
+var EventTypes = []string{"problem", "resolution"}
+
+// ValidationError lists every violation found, so a payload can be fixed in one go
+type ValidationError struct {
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d violation(s): %v", len(e.Violations), e.Violations)
+}
+
+type validator struct {
+	violations []string
+}
+
+func (v *validator) addf(format string, args ...any) {
+	v.violations = append(v.violations, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: v.violations}
+}
+
+func (v *validator) severity(severity int) {
+	if severity < MinSeverity || severity > MaxSeverity {
+		v.addf("severity %d is out of range %d-%d", severity, MinSeverity, MaxSeverity)
+	}
+}
+
+func (v *validator) eventType(t EvType) {
+	if !slices.Contains(EventTypes, t.EventType) {
+		v.addf("type.eventType %q must be one of %v", t.EventType, EventTypes)
+	}
+}
+
+func (v *validator) resource(name string, r EvResource) {
+	//the same fields used for the deduplication key, port excluded as 0 is its zero value
+	if r.Name == "" && r.SourceId == "" && r.Hostname == "" && r.IpAddress == "" && r.Service == "" &&
+		r.Interface == "" && r.Application == "" && r.Controller == "" && r.Component == "" && r.Cluster == "" {
+		v.addf("%s has no identifying field", name)
+	}
+	if r.Port < 0 || r.Port > 65535 {
+		v.addf("%s.port %d is out of range", name, r.Port)
+	}
+}
+
+func (v *validator) links(links []EvLink) {
+	for i, l := range links {
+		u, err := url.Parse(l.Url)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			v.addf("links[%d].url %q is not an absolute URL", i, l.Url)
+		}
+	}
+}
+
+func (v *validator) expiry(seconds int) {
+	if seconds < 0 || seconds > MaxExpirySeconds {
+		v.addf("expirySeconds %d is out of range 0-%d", seconds, MaxExpirySeconds)
+	}
+}
+
+func (v *validator) size(payload any) {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		v.addf("payload cannot be encoded: %v", err)
+		return
+	}
+	if len(content) > MaxPayloadBytes {
+		v.addf("payload of %d bytes exceeds %d", len(content), MaxPayloadBytes)
+	}
+}
+
+// Validate checks the event is acceptable to the platform, returning a *ValidationError with all the violations
+func (e *EvEvent) Validate() error {
+	v := &validator{}
+	if e.Summary == "" {
+		v.addf("summary is required")
+	}
+	if e.Type.Classification == "" {
+		v.addf("type.classification is required")
+	}
+	v.severity(e.Severity)
+	v.eventType(e.Type)
+	v.resource("resource", e.Resource)
+	v.links(e.Links)
+	v.expiry(e.ExpirySeconds)
+	v.size(e)
+	return v.err()
+}
+
+// Validate checks the alert is acceptable to the platform, returning a *ValidationError with all the violations
+func (a *EvAlert) Validate() error {
+	v := &validator{}
+	if a.Summary == "" {
+		v.addf("summary is required")
+	}
+	if a.DeduplicationKey == "" {
+		v.addf("deduplicationKey is required")
+	}
+	if a.Type.Classification == "" {
+		v.addf("type.classification is required")
+	}
+	if !slices.Contains([]string{"open", "clear", "closed"}, a.State) {
+		v.addf("state %q must be one of open, clear, closed", a.State)
+	}
+	if a.EventCount < 0 {
+		v.addf("eventCount %d is negative", a.EventCount)
+	}
+	v.severity(a.Severity)
+	v.eventType(a.Type)
+	v.resource("resource", a.Resource)
+	v.links(a.Links)
+	v.expiry(a.ExpirySeconds)
+	v.size(a)
+	return v.err()
+}
+
+// Violations returns the violations of a validation error, nil for any other error
+func Violations(err error) []string {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve.Violations
+	}
+	return nil
+}
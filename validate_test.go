@@ -0,0 +1,89 @@
+package waiops
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRandomEventsAndAlertsAreValid(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		event := NewRandomEvent()
+		if err := event.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		alert := NewRandomAlert()
+		if err := alert.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// expectViolations fails unless the violations are the expected ones, in order
+func expectViolations(t *testing.T, err error, want ...string) {
+	t.Helper()
+	violations := Violations(err)
+	if !slices.Equal(violations, want) {
+		t.Fatalf("expected the violations\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(violations, "\n"))
+	}
+}
+
+func TestValidateReportsAllViolations(t *testing.T) {
+	event := NewRandomEvent()
+	event.Summary = ""
+	event.Severity = 9
+	event.Type = EvType{EventType: "warning"}
+	event.Resource = EvResource{Port: 70000}
+	event.Links = []EvLink{{Url: "not a url"}}
+	event.ExpirySeconds = -1
+
+	expectViolations(t, event.Validate(),
+		"summary is required",
+		"type.classification is required",
+		"severity 9 is out of range 1-6",
+		`type.eventType "warning" must be one of [problem resolution]`,
+		"resource has no identifying field",
+		"resource.port 70000 is out of range",
+		`links[0].url "not a url" is not an absolute URL`,
+		"expirySeconds -1 is out of range 0-2592000",
+	)
+}
+
+func TestValidateAlert(t *testing.T) {
+	alert := NewRandomAlert()
+	alert.DeduplicationKey = ""
+	alert.State = "acknowledged"
+	alert.EventCount = -1
+	alert.Severity = 0
+	alert.ExpirySeconds = MaxExpirySeconds + 1
+
+	expectViolations(t, alert.Validate(),
+		"deduplicationKey is required",
+		`state "acknowledged" must be one of open, clear, closed`,
+		"eventCount -1 is negative",
+		"severity 0 is out of range 1-6",
+		"expirySeconds 2592001 is out of range 0-2592000",
+	)
+
+	alert = NewRandomAlert()
+	alert.ExpirySeconds = MaxExpirySeconds
+	if err := alert.Validate(); err != nil {
+		t.Fatalf("expect the maximum expiry accepted, got %v", err)
+	}
+}
+
+func TestValidatePayloadSize(t *testing.T) {
+	event := NewRandomEvent()
+	event.Details = map[string]string{"dump": strings.Repeat("x", MaxPayloadBytes)}
+
+	violations := Violations(event.Validate())
+	if len(violations) != 1 || !strings.HasPrefix(violations[0], "payload of ") || !strings.HasSuffix(violations[0], " bytes exceeds 262144") {
+		t.Fatalf("expected the payload size violation only, got %v", violations)
+	}
+
+	defer func(max int) { MaxPayloadBytes = max }(MaxPayloadBytes)
+	MaxPayloadBytes = 2 * 1024 * 1024
+	if err := event.Validate(); err != nil {
+		t.Fatalf("expect the payload accepted under a raised limit, got %v", err)
+	}
+}